@@ -0,0 +1,131 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"geerpc/codec"
+)
+
+// Notify 单向调用，发送请求后立即返回，服务端执行方法但不发送响应。
+// 返回的错误只表示请求是否发送成功，方法的错误只会打印在服务端的日志中。
+// ctx 中的元数据随请求发送，剩余超时时间同样限制服务端的执行时间。
+func (client *Client) Notify(ctx context.Context, serviceMethod string, args any) error {
+	if client.interceptor == nil {
+		return client.notify(ctx, serviceMethod, args, nil)
+	}
+	return client.interceptor(ctx, serviceMethod, args, nil, client.notify)
+}
+
+// notify 发送单向调用，是 Notify 的拦截器链最终执行的 UnaryInvoker，reply 被忽略
+func (client *Client) notify(ctx context.Context, serviceMethod string, args, _ any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	client.sending.Lock()
+	defer client.sending.Unlock()
+
+	client.mu.Lock()
+	var err error
+	if client.draining && !client.closing {
+		err = ErrServerShutdown
+	} else if client.closing || client.shutdown {
+		err = ErrShutdown
+	}
+	client.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 不占用 seq，服务端不会响应
+	h := &codec.Header{ServiceMethod: serviceMethod, Metadata: outgoingMetadata(ctx), Flag: codec.FlagOneWay}
+	return client.cc.Write(h, args)
+}
+
+// Batch 在一次写入中发送多个调用并等待全部完成，减少对 sending 的竞争和系统调用。
+// calls 只需要设置 ServiceMethod、Args 和 Reply，Done 会被替换。
+// 每个调用的错误保存在各自的 Call.Error 中，返回第一个出错的调用的错误；
+// ctx 结束时未完成的调用被移除并返回 ctx.Err()，正在读取的响应会等待读取完成，返回后不会再写入 Reply。
+func (client *Client) Batch(ctx context.Context, calls ...*Call) error {
+	md := outgoingMetadata(ctx)
+	done := make(chan *Call, len(calls))
+	for _, call := range calls {
+		call.Seq = 0
+		call.Error = nil
+		call.Done = done
+		call.metadata = md
+	}
+	client.sendBatch(calls)
+
+	for remaining := len(calls); remaining > 0; remaining-- {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// 移除成功的调用不会再完成，其余的已经由 receive 取出，可能正在写入 Reply，需要等待完成
+			for _, call := range calls {
+				if client.removeCall(call.Seq) != nil {
+					remaining--
+				}
+			}
+			for ; remaining > 0; remaining-- {
+				<-done
+			}
+			return ctx.Err()
+		}
+	}
+
+	for _, call := range calls {
+		if call.Error != nil {
+			return call.Error
+		}
+	}
+	return nil
+}
+
+// sendBatch 注册所有的调用，并在持有一次 sending 的情况下写入
+func (client *Client) sendBatch(calls []*Call) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+
+	msgs := make([]codec.Message, 0, len(calls))
+	sent := make([]*Call, 0, len(calls))
+	for _, call := range calls {
+		seq, err := client.registerCall(call)
+		if err != nil {
+			call.Error = err
+			call.done()
+			continue
+		}
+
+		h := &codec.Header{ServiceMethod: call.ServiceMethod, Seq: seq, Metadata: call.metadata}
+		msgs = append(msgs, codec.Message{Header: h, Body: call.Args})
+		sent = append(sent, call)
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	var err error
+	if bc, ok := client.cc.(codec.BatchCodec); ok {
+		err = bc.WriteBatch(msgs)
+	} else {
+		for _, msg := range msgs {
+			if err = client.cc.Write(msg.Header, msg.Body); err != nil {
+				break
+			}
+		}
+	}
+
+	// 写入出错时连接已经关闭，还没有收到响应的调用都以该错误结束
+	if err != nil {
+		for _, call := range sent {
+			if call := client.removeCall(call.Seq); call != nil {
+				call.Error = err
+				call.done()
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Counter 用于检查单向调用是否执行
+type Counter struct {
+	n int64
+}
+
+func (c *Counter) Add(n int64, reply *int64) error {
+	*reply = atomic.AddInt64(&c.n, n)
+	return nil
+}
+
+func TestClient_Notify(t *testing.T) {
+	server, l := startServer(t)
+	defer func() { _ = l.Close() }()
+	counter := new(Counter)
+	_ = server.Register(counter)
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := client.Notify(ctx, "Counter.Add", int64(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 方法不存在只会打印日志，不影响后续请求
+	if err := client.Notify(ctx, "Counter.Unknown", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&counter.n) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect 3 one-way calls executed, got %d", atomic.LoadInt64(&counter.n))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var reply int
+	if err := client.Call(ctx, "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+
+	_ = client.Close()
+	if err := client.Notify(ctx, "Counter.Add", int64(1)); err != ErrShutdown {
+		t.Fatalf("expect ErrShutdown, got %v", err)
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	calls := make([]*Call, 10)
+	for i := range calls {
+		calls[i] = &Call{ServiceMethod: "Foo.Sum", Args: &Args{Num1: i, Num2: i}, Reply: new(int)}
+	}
+	if err := client.Batch(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+	for i, call := range calls {
+		if reply := *call.Reply.(*int); call.Error != nil || reply != i*2 {
+			t.Fatalf("call %d: expect %d, but got %d, err: %v", i, i*2, reply, call.Error)
+		}
+	}
+
+	// 单个调用出错不影响其他调用
+	calls = []*Call{
+		{ServiceMethod: "Foo.Sum", Args: &Args{Num1: 1, Num2: 1}, Reply: new(int)},
+		{ServiceMethod: "Foo.Unknown", Args: &Args{}, Reply: new(int)},
+	}
+	if err := client.Batch(context.Background(), calls...); err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("expect can't find method error, got %v", err)
+	}
+	if calls[0].Error != nil || *calls[0].Reply.(*int) != 2 {
+		t.Fatalf("expect first call succeeded, err: %v", calls[0].Error)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	calls = []*Call{{ServiceMethod: "Foo.Sleep", Args: &Args{Num1: 1}, Reply: new(int)}}
+	if err := client.Batch(ctx, calls...); err != context.DeadlineExceeded {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClient_BatchCancelWhileReceiving(t *testing.T) {
+	client := startSlowClient(t)
+
+	// ctx 在读取响应的过程中结束，Batch 返回后调用方可以立即使用每个 reply，配合 -race 检查
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	calls := make([]*Call, 3)
+	for i := range calls {
+		calls[i] = &Call{ServiceMethod: "Slow.Get", Args: i, Reply: new(SlowReply)}
+	}
+	if err := client.Batch(ctx, calls...); err != context.DeadlineExceeded {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	for _, call := range calls {
+		call.Reply.(*SlowReply).N = 0
+	}
+}
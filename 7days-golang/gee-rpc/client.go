@@ -5,14 +5,20 @@
 package geerpc
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"geerpc/codec"
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Call 代表一个执行的 RPC
@@ -23,6 +29,8 @@ type Call struct {
 	Reply         any        // Reply method 函数的返回
 	Error         error      // Error 如果发生错误，将被设置值
 	Done          chan *Call // Done 标记完成
+
+	metadata Metadata // metadata 随请求发送的元数据
 }
 
 // done 标记完成
@@ -39,11 +47,18 @@ type Client struct {
 	mu      sync.Mutex       // mu 互斥锁
 	seq     uint64           // seq 用于给发送的请求编号，每个请求拥有唯一编号。
 	pending map[uint64]*Call // pending 存储未处理完的请求，键是编号，值是 Call 实例。
+	streams *streamSet       // streams 存储未结束的流，和 pending 共用 seq。
 
 	// closing 和 shutdown 任意一个值置为 true，则表示 Client 处于不可用的状态。
 	// 但有些许的差别，closing 是用户主动关闭的，即调用 Close 方法，而 shutdown 置为 true 一般是有错误发生。
 	closing  bool // closing 用户主动关闭
 	shutdown bool // shutdown 执行过程错误导致的停止
+	draining bool // draining 服务端通知即将关闭，不再发送新的请求，已发送的请求继续等待响应
+
+	done chan struct{} // done receive 退出即连接断开后关闭
+
+	interceptors []UnaryClientInterceptor // interceptors 客户端拦截器
+	interceptor  UnaryClientInterceptor   // interceptor 合并后的拦截器，没有拦截器时为 nil
 }
 
 // 检查 Client 是否实现了 io.Closer 接口
@@ -51,6 +66,9 @@ var _ io.Closer = (*Client)(nil)
 
 var ErrShutdown = errors.New("connection is shutdown")
 
+// ErrServerShutdown 服务端正在关闭，调用方可以换一个服务端重试
+var ErrServerShutdown = errors.New("rpc client: server is shutting down")
+
 // Close 关闭连接
 func (client *Client) Close() error {
 	client.mu.Lock()
@@ -68,7 +86,7 @@ func (client *Client) Close() error {
 func (client *Client) IsAvailable() bool {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	return !client.shutdown && !client.closing
+	return !client.shutdown && !client.closing && !client.draining
 }
 
 // registerCall 将参数 Call 添加到 Client.pending 中，并更新 Client.seq。
@@ -76,6 +94,9 @@ func (client *Client) registerCall(call *Call) (uint64, error) {
 	client.mu.Lock()
 	defer client.mu.Unlock()
 
+	if client.draining && !client.closing {
+		return 0, ErrServerShutdown
+	}
 	if client.closing || client.shutdown {
 		return 0, ErrShutdown
 	}
@@ -98,14 +119,25 @@ func (client *Client) removeCall(seq uint64) *Call {
 // terminateCalls 服务端或客户端发生错误时调用，将 shutdown 设置为 true，且将错误信息通知所有 pending 状态的 Call。
 func (client *Client) terminateCalls(err error) {
 	client.sending.Lock()
-	defer client.sending.Unlock()
 	client.mu.Lock()
-	defer client.mu.Unlock()
 	client.shutdown = true
+	// 服务端关闭时未完成的请求，需要让调用方知道可以换一个服务端重试
+	if client.draining {
+		err = ErrServerShutdown
+	}
 	for _, call := range client.pending {
 		call.Error = err
 		call.done()
 	}
+	client.mu.Unlock()
+	client.sending.Unlock()
+
+	// Stream.Send 持有流的锁再获取 sending，所以需要在释放 sending 后结束流。
+	// 连接断开不是流的正常结束，不能返回 io.EOF
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	client.streams.closeAll(err)
 }
 
 // send 发送消息
@@ -123,6 +155,7 @@ func (client *Client) send(call *Call) {
 	client.header.ServiceMethod = call.ServiceMethod
 	client.header.Seq = seq
 	client.header.Error = ""
+	client.header.Metadata = call.metadata
 
 	if err := client.cc.Write(&client.header, call.Args); err != nil {
 		call := client.removeCall(seq)
@@ -146,13 +179,26 @@ func (client *Client) receive() {
 		if err = client.cc.ReadHeader(&h); err != nil {
 			break
 		}
-		client.header = h
+
+		if isStreamFrame(h.Flag) {
+			err = client.receiveStreamFrame(&h)
+			continue
+		}
+
+		if h.Flag == codec.FlagShutdown {
+			client.mu.Lock()
+			client.draining = true
+			client.mu.Unlock()
+			err = client.cc.ReadBody(nil)
+			continue
+		}
+
 		call := client.removeCall(h.Seq)
 		switch {
 		case call == nil: // Call 不存在，可能是请求没有发送完整，或者因为其他原因被取消，但是服务端仍旧处理了。
 			err = client.cc.ReadBody(nil)
 		case h.Error != "": // Call 存在，但服务端处理出错，即 h.Error 不为空。
-			call.Error = fmt.Errorf(h.Error)
+			call.Error = serverError(h.Error)
 			err = client.cc.ReadBody(nil)
 			call.done()
 		default: // Call 存在，服务端处理正常，那么需要从 body 中读取 Reply 的值。
@@ -165,10 +211,12 @@ func (client *Client) receive() {
 	}
 	// 遇到错误就停止所有请求
 	client.terminateCalls(err)
+	close(client.done)
 }
 
 // Go 和 Call 是客户端暴露给用户的两个 RPC 服务调用接口
 // Go 是一个异步接口，返回 call 实例。
+// 有拦截器时在新的协程中经过拦截器发送，返回的 call 的 Seq 不会被设置。
 func (client *Client) Go(serviceMethod string, args, reply any, done chan *Call) *Call {
 	if done == nil {
 		done = make(chan *Call, 10)
@@ -182,15 +230,154 @@ func (client *Client) Go(serviceMethod string, args, reply any, done chan *Call)
 		Reply:         reply,
 		Done:          done,
 	}
-	client.send(call)
+	if client.interceptor == nil {
+		client.send(call)
+		return call
+	}
+
+	go func() {
+		call.Error = client.interceptor(context.Background(), serviceMethod, args, reply, client.invoke)
+		call.done()
+	}()
 	return call
 }
 
+// Use 添加客户端拦截器，按添加的顺序执行，对 Call、Go 和 Notify 生效，Notify 时 reply 为 nil。
+// Batch 和 NewStream 不经过拦截器。需要在发起调用前调用。
+func (client *Client) Use(interceptors ...UnaryClientInterceptor) {
+	client.interceptors = append(client.interceptors, interceptors...)
+	client.interceptor = chainUnaryClientInterceptors(client.interceptors)
+}
+
 // Call 是对 Go 的封装，阻塞 Call.Done，等待响应返回，是一个同步接口。
-func (client *Client) Call(serviceMethod string, args, replay any) error {
-	call := <-client.Go(serviceMethod, args, replay, make(chan *Call, 1)).Done
-	log.Println("返回的 header 的内容：", call)
-	return call.Error
+// 超时或取消由 ctx 控制，ctx 结束时会将 Call 从 pending 中移除并返回 ctx.Err()；
+// 响应正在被读取时等待读取完成，返回后不会再写入 reply。
+func (client *Client) Call(ctx context.Context, serviceMethod string, args, replay any) error {
+	if client.interceptor == nil {
+		return client.invoke(ctx, serviceMethod, args, replay)
+	}
+	return client.interceptor(ctx, serviceMethod, args, replay, client.invoke)
+}
+
+// Caller 可以发起同步调用的客户端，Client、Pool 和 xclient.XClient 都实现了该接口，
+// geerpc-gen 生成的客户端基于 Caller。
+type Caller interface {
+	Call(ctx context.Context, serviceMethod string, args, reply any) error
+}
+
+var (
+	_ Caller = (*Client)(nil)
+	_ Caller = (*Pool)(nil)
+)
+
+// invoke 发送请求并等待响应，是拦截器链最终执行的 UnaryInvoker。
+// ctx 中的元数据和剩余超时时间会随请求发送。
+func (client *Client) invoke(ctx context.Context, serviceMethod string, args, replay any) error {
+	call := &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         replay,
+		Done:          make(chan *Call, 1),
+		metadata:      outgoingMetadata(ctx),
+	}
+	client.send(call)
+
+	select {
+	case <-ctx.Done():
+		if client.removeCall(call.Seq) == nil {
+			// receive 已经取出了 Call，可能正在向 reply 写入，等它完成后再返回，调用方才能安全地使用 reply
+			<-call.Done
+		}
+		return ctx.Err()
+	case call := <-call.Done:
+		return call.Error
+	}
+}
+
+// NewStream 打开一个流，args 为流式方法的参数，reply 用于指定服务端消息的类型，例如：new(string)。
+// ctx 结束时流被取消，服务端方法的 ctx 也会被取消。
+func (client *Client) NewStream(ctx context.Context, serviceMethod string, args, reply any) (*Stream, error) {
+	if reflect.TypeOf(reply) == nil || reflect.TypeOf(reply).Kind() != reflect.Ptr {
+		return nil, errors.New("rpc client: stream reply must be a pointer")
+	}
+
+	write := func(h *codec.Header, body any) error {
+		client.sending.Lock()
+		defer client.sending.Unlock()
+		return client.cc.Write(h, body)
+	}
+
+	// 和 registerCall 一样分配 seq
+	client.mu.Lock()
+	if client.draining && !client.closing {
+		client.mu.Unlock()
+		return nil, ErrServerShutdown
+	}
+	if client.closing || client.shutdown {
+		client.mu.Unlock()
+		return nil, ErrShutdown
+	}
+	stream := newStream(ctx, serviceMethod, client.seq, reflect.TypeOf(reply), write)
+	client.seq++
+	client.streams.add(stream)
+	client.mu.Unlock()
+
+	h := &codec.Header{
+		ServiceMethod: serviceMethod,
+		Seq:           stream.seq,
+		Metadata:      outgoingMetadata(ctx),
+		Flag:          codec.FlagStreamOpen,
+	}
+	if err := write(h, args); err != nil {
+		client.streams.remove(stream.seq)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// 通知服务端取消，之后收到的消息直接丢弃
+			client.streams.remove(stream.seq)
+			stream.closeRecv(ctx.Err())
+			_ = stream.sendEnd(ctx.Err().Error())
+		case <-stream.done:
+		}
+	}()
+	return stream, nil
+}
+
+// receiveStreamFrame 读取流中的消息、结束帧或窗口，流不存在时丢弃
+func (client *Client) receiveStreamFrame(h *codec.Header) error {
+	stream := client.streams.get(h.Seq)
+	if stream == nil {
+		return client.cc.ReadBody(nil)
+	}
+
+	if h.Flag == codec.FlagStreamCredit {
+		return stream.readCredit(client.cc)
+	}
+
+	if h.Flag == codec.FlagStreamMsg {
+		err := stream.readMsg(client.cc)
+		if err == ErrStreamOverflow {
+			// 服务端没有遵守流量控制，通知服务端结束流，在新的协程中发送，避免读取协程等待 sending
+			client.streams.remove(h.Seq)
+			go func() { _ = stream.sendEnd(ErrStreamOverflow.Error()) }()
+			return nil
+		}
+		return err
+	}
+
+	// 服务端结束了流，不能再发送
+	client.streams.remove(h.Seq)
+	stream.closeSend()
+	err := client.cc.ReadBody(nil)
+	if h.Error != "" {
+		stream.closeRecv(serverError(h.Error))
+	} else {
+		stream.closeRecv(nil)
+	}
+	return err
 }
 
 // parseOptions 解析 Option， 验证参数，并赋值默认值
@@ -204,8 +391,11 @@ func parseOptions(opts ...*Option) (*Option, error) {
 		return nil, errors.New("number of options is more than 1")
 	}
 
-	opt := opts[0]
+	// 复制一份，同一个 Option 可能被多个连接并发使用
+	o := *opts[0]
+	opt := &o
 	opt.MagicNumber = DefaultOption.MagicNumber
+	// ConnectTimeout 为 0 表示不限制，所以不赋默认值
 
 	if opt.CodecType == "" {
 		opt.CodecType = DefaultOption.CodecType
@@ -215,7 +405,7 @@ func parseOptions(opts ...*Option) (*Option, error) {
 }
 
 // NewClient 创建 Client
-// 创建 Client 实例时，首先需要完成一开始的协议交换，即发送 Option 信息给服务端。
+// 创建 Client 实例时，首先需要完成一开始的协议交换，即发送前导给服务端并等待服务端确认协议版本和编码类型。
 // 协商好消息的编解码方式之后，再创建一个子协程调用 receive() 接收响应。
 func NewClient(conn net.Conn, opt *Option) (*Client, error) {
 	f := codec.NewCodecFuncMap[opt.CodecType]
@@ -227,13 +417,26 @@ func NewClient(conn net.Conn, opt *Option) (*Client, error) {
 		return nil, err
 	}
 
-	if err := json.NewEncoder(conn).Encode(opt); err != nil {
+	if err := writePreamble(conn, ProtocolVersion, opt); err != nil {
 		log.Println("rpc client: options error: ", err)
 		_ = conn.Close()
 		return nil, err
 	}
 
-	return newClientCodec(f(conn), opt), nil
+	// 等待服务端确认协议版本和编码类型
+	if err := readHandshakeStatus(conn); err != nil {
+		log.Println("rpc client: handshake error: ", err)
+		_ = conn.Close()
+		return nil, err
+	}
+
+	cc := f(conn)
+	if err := setCompressor(cc, opt); err != nil {
+		log.Println("rpc client: ", err)
+		_ = cc.Close()
+		return nil, err
+	}
+	return newClientCodec(cc, opt), nil
 }
 
 // newClientCodec 创建消息编码，并创建一个子携程调用 receive() 接收响应。
@@ -243,11 +446,76 @@ func newClientCodec(cc codec.Codec, opt *Option) *Client {
 		cc:      cc,
 		opt:     opt,
 		pending: make(map[uint64]*Call),
+		streams: newStreamSet(),
+		done:    make(chan struct{}),
 	}
 	go client.receive()
 	return client
 }
 
+// clientResult NewClient 的执行结果，用于在超时控制中传递
+type clientResult struct {
+	client *Client
+	err    error
+}
+
+// newClientFunc 在连接上创建 Client 的方法，例如：NewClient
+type newClientFunc func(conn net.Conn, opt *Option) (client *Client, err error)
+
+// dialTimeout 建立连接并创建 Client，连接和协议交换都受 Option.ConnectTimeout 的限制
+func dialTimeout(f newClientFunc, network, address string, opt *Option) (client *Client, err error) {
+	conn, err := net.DialTimeout(network, address, opt.ConnectTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// TLS 握手在 f 第一次写入时进行，同样受 ConnectTimeout 的限制
+	if opt.TLSConfig != nil {
+		conn = tls.Client(conn, tlsClientConfig(opt.TLSConfig, address))
+	}
+
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	// 带缓冲，超时返回后 NewClient 执行完成也不会阻塞
+	ch := make(chan clientResult, 1)
+	go func() {
+		client, err := f(conn, opt)
+		ch <- clientResult{client: client, err: err}
+	}()
+
+	if opt.ConnectTimeout == 0 {
+		result := <-ch
+		return result.client, result.err
+	}
+
+	select {
+	case <-time.After(opt.ConnectTimeout):
+		return nil, fmt.Errorf("rpc client: connect timeout: expect within %s", opt.ConnectTimeout)
+	case result := <-ch:
+		return result.client, result.err
+	}
+}
+
+// tlsClientConfig 没有设置 ServerName 时使用 address 的 host 验证服务端证书
+func tlsClientConfig(config *tls.Config, address string) *tls.Config {
+	if config.ServerName != "" || config.InsecureSkipVerify {
+		return config
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	c := config.Clone()
+	c.ServerName = host
+	return c
+}
+
 // Dial 实现 Dial 函数，便于用户传入服务端地址，创建 Client 实例。
 // 为了简化用户调用，通过 ...*Option 将 Option 实现为可选参数。
 func Dial(network, address string, opts ...*Option) (client *Client, err error) {
@@ -257,17 +525,65 @@ func Dial(network, address string, opts ...*Option) (client *Client, err error)
 		return nil, err
 	}
 
-	conn, err := net.Dial(network, address)
+	return dialTimeout(NewClient, network, address, opt)
+}
+
+// DialTimeout 同 Dial，使用 timeout 作为建立连接的超时时间
+func DialTimeout(network, address string, timeout time.Duration, opts ...*Option) (client *Client, err error) {
+	opt, err := parseOptions(opts...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		if err != nil {
-			_ = conn.Close()
-		}
-	}()
+	// 复制一份，避免修改 DefaultOption
+	o := *opt
+	o.ConnectTimeout = timeout
+	return dialTimeout(NewClient, network, address, &o)
+}
+
+// NewHTTPClient 通过 HTTP CONNECT 建立 RPC 连接，成功后的通信和 NewClient 一致
+func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
+	_, _ = io.WriteString(conn, fmt.Sprintf("CONNECT %s HTTP/1.0\n\n", defaultRPCPath))
 
-	return NewClient(conn, opt)
+	// 需要在切换到 RPC 协议前读到成功的响应
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status == connected {
+		return NewClient(conn, opt)
+	}
+
+	if err == nil {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	return nil, err
+}
+
+// DialHTTP 通过 HTTP 连接到指定地址的 RPC 服务
+func DialHTTP(network, address string, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dialTimeout(NewHTTPClient, network, address, opt)
+}
+
+// XDial 根据 rpcAddr 的协议选择对应的连接方式，rpcAddr 格式为 protocol@addr，例如：
+//
+//	http@10.0.0.1:7001, tcp@10.0.0.1:9999, unix@/tmp/geerpc.sock
+func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
+	parts := strings.Split(rpcAddr, "@")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rpc client err: wrong format '%s', expect protocol@addr", rpcAddr)
+	}
+
+	protocol, addr := parts[0], parts[1]
+	switch protocol {
+	case "http":
+		return DialHTTP("tcp", addr, opts...)
+	default:
+		// tcp, unix 或者其他传输协议
+		return Dial(protocol, addr, opts...)
+	}
 }
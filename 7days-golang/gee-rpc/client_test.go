@@ -0,0 +1,227 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer 在随机端口上开启一个注册了 Foo 的服务
+func startServer(t *testing.T) (*Server, net.Listener) {
+	server := NewServer()
+	var foo Foo
+	if err := server.Register(&foo); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	return server, l
+}
+
+func TestClient_dialTimeout(t *testing.T) {
+	t.Parallel()
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+
+	f := func(conn net.Conn, opt *Option) (client *Client, err error) {
+		_ = conn.Close()
+		time.Sleep(time.Second * 2)
+		return nil, nil
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := dialTimeout(f, "tcp", l.Addr().String(), &Option{ConnectTimeout: time.Second})
+		if err == nil || !strings.Contains(err.Error(), "connect timeout") {
+			t.Fatal("expect a timeout error")
+		}
+	})
+
+	t.Run("0", func(t *testing.T) {
+		_, err := dialTimeout(f, "tcp", l.Addr().String(), &Option{ConnectTimeout: 0})
+		if err != nil {
+			t.Fatal("0 means no limit")
+		}
+	})
+}
+
+func TestClient_Call(t *testing.T) {
+	t.Parallel()
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+	addr := l.Addr().String()
+
+	t.Run("client timeout", func(t *testing.T) {
+		client, _ := Dial("tcp", addr)
+		defer func() { _ = client.Close() }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var reply int
+		err := client.Call(ctx, "Foo.Sleep", &Args{Num1: 2}, &reply)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expect context.DeadlineExceeded, but got %v", err)
+		}
+
+		client.mu.Lock()
+		pending := len(client.pending)
+		client.mu.Unlock()
+		if pending != 0 {
+			t.Fatal("timeout call should be removed from pending")
+		}
+	})
+
+	t.Run("server handle timeout", func(t *testing.T) {
+		client, _ := Dial("tcp", addr, &Option{HandleTimeout: time.Second})
+		defer func() { _ = client.Close() }()
+
+		var reply int
+		err := client.Call(context.Background(), "Foo.Sleep", &Args{Num1: 2}, &reply)
+		if err == nil || !strings.Contains(err.Error(), "handle timeout") {
+			t.Fatalf("expect a timeout error, but got %v", err)
+		}
+	})
+}
+
+// SlowReply 解码时等待一段时间，模拟读取较大的响应
+type SlowReply struct {
+	N int
+}
+
+func (r *SlowReply) GobEncode() ([]byte, error) {
+	return []byte{byte(r.N)}, nil
+}
+
+func (r *SlowReply) GobDecode(b []byte) error {
+	time.Sleep(time.Millisecond * 50)
+	r.N = int(b[0])
+	return nil
+}
+
+// Slow 立即返回 SlowReply，客户端读取 body 时较慢
+type Slow int
+
+func (s Slow) Get(n int, reply *SlowReply) error {
+	reply.N = n
+	return nil
+}
+
+// startSlowClient 开启注册了 Slow 的服务并返回连接到服务的 Client
+func startSlowClient(t *testing.T) *Client {
+	server := NewServer()
+	_ = server.Register(new(Slow))
+	l, _ := net.Listen("tcp", ":0")
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestClient_CallCancelWhileReceiving(t *testing.T) {
+	client := startSlowClient(t)
+
+	// ctx 在读取响应的过程中结束，Call 返回后调用方可以立即使用 reply，配合 -race 检查
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	reply := new(SlowReply)
+	if err := client.Call(ctx, "Slow.Get", 1, reply); err != context.DeadlineExceeded {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	reply.N = 0
+}
+
+func TestXDial(t *testing.T) {
+	if runtime.GOOS == "linux" {
+		server := NewServer()
+		var foo Foo
+		_ = server.Register(&foo)
+
+		addr := filepath.Join(os.TempDir(), "geerpc.sock")
+		_ = os.Remove(addr)
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			t.Fatal("failed to listen unix socket")
+		}
+		defer func() { _ = l.Close() }()
+		go server.Accept(l)
+
+		client, err := XDial("unix@" + addr)
+		if err != nil {
+			t.Fatal("failed to connect unix socket:", err)
+		}
+		defer func() { _ = client.Close() }()
+
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+		}
+	}
+
+	if _, err := XDial("127.0.0.1:9999"); err == nil {
+		t.Fatal("expect a wrong format error")
+	}
+}
+
+func TestDialHTTP(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	mux := http.NewServeMux()
+	mux.Handle(defaultRPCPath, server)
+	mux.Handle(defaultDebugPath, debugHTTP{server})
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go func() { _ = http.Serve(l, mux) }()
+
+	addr := l.Addr().String()
+	client, err := XDial("http@" + addr)
+	if err != nil {
+		t.Fatal("failed to connect by http:", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+
+	resp, err := http.Get("http://" + addr + defaultDebugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "Service Foo") || !strings.Contains(string(body), "Sum(") {
+		t.Fatalf("debug page should list Foo.Sum, but got %s", body)
+	}
+
+	// 非 CONNECT 请求返回 405
+	resp2, err := http.Get("http://" + addr + defaultRPCPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp2.Body.Close()
+	if resp2.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405, but got %d", resp2.StatusCode)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// fileDesc 一个源文件中需要生成代码的所有服务
+type fileDesc struct {
+	Source   string        // Source 源文件名，写入生成文件的注释
+	Package  string        // Package 源文件的包名，生成的文件使用同一个包
+	Geerpc   string        // Geerpc geerpc 包的导入路径
+	Imports  []string      // Imports 参数和返回值类型用到的其他包，已经带引号，可能带别名
+	Services []serviceDesc // Services 需要生成代码的接口
+}
+
+// serviceDesc 一个接口定义的服务，接口名即服务名
+type serviceDesc struct {
+	Name    string
+	Methods []methodDesc
+}
+
+// methodDesc 服务的一个方法，形如：
+//
+//	Method(ctx context.Context, args T1) (*T2, error)
+type methodDesc struct {
+	Name     string
+	Args     string // Args 参数的类型，例如：*Args
+	ArgsZero string // ArgsZero 参数的零值表达式，生成的测试中使用，例如：new(Args)
+	Reply    string // Reply 返回值指向的类型，例如：Reply
+}
+
+// parse 解析源文件中的接口，types 不为空时只处理其中的接口，否则处理所有导出的接口
+func parse(filename string, src []byte, types []string) (*fileDesc, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]bool)
+	for _, t := range types {
+		want[t] = true
+	}
+
+	fd := &fileDesc{Source: path.Base(filename), Package: f.Name.Name}
+	found := make(map[string]bool)
+	used := make(map[string]bool)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			if len(want) > 0 && !want[ts.Name.Name] || len(want) == 0 && !ts.Name.IsExported() {
+				continue
+			}
+			found[ts.Name.Name] = true
+
+			sd, err := parseService(fset, ts.Name.Name, it, used)
+			if err != nil {
+				return nil, err
+			}
+			fd.Services = append(fd.Services, sd)
+		}
+	}
+
+	for _, t := range types {
+		if found[t] {
+			continue
+		}
+		return nil, fmt.Errorf("geerpc-gen: interface %s not found in %s", t, filename)
+	}
+	if len(fd.Services) == 0 {
+		return nil, fmt.Errorf("geerpc-gen: no service interface found in %s", filename)
+	}
+
+	fd.Imports = usedImports(f, used)
+	return fd, nil
+}
+
+// parseService 解析一个接口，used 记录类型中引用的包名
+func parseService(fset *token.FileSet, name string, it *ast.InterfaceType, used map[string]bool) (serviceDesc, error) {
+	sd := serviceDesc{Name: name}
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			return sd, fmt.Errorf("geerpc-gen: %s: embedded interface is not supported", fset.Position(field.Pos()))
+		}
+
+		params, results := flatten(ft.Params), flatten(ft.Results)
+		pos := fset.Position(field.Pos())
+		if len(params) != 2 || exprString(fset, params[0]) != "context.Context" {
+			return sd, fmt.Errorf("geerpc-gen: %s: %s.%s must have parameters (context.Context, args)", pos, name, field.Names[0])
+		}
+		if len(results) != 2 || exprString(fset, results[1]) != "error" {
+			return sd, fmt.Errorf("geerpc-gen: %s: %s.%s must return (*Reply, error)", pos, name, field.Names[0])
+		}
+		star, ok := results[0].(*ast.StarExpr)
+		if !ok {
+			return sd, fmt.Errorf("geerpc-gen: %s: %s.%s must return (*Reply, error)", pos, name, field.Names[0])
+		}
+
+		args := params[1]
+		zero := "*new(" + exprString(fset, args) + ")"
+		if p, ok := args.(*ast.StarExpr); ok {
+			zero = "new(" + exprString(fset, p.X) + ")"
+		}
+		collectPackages(args, used)
+		collectPackages(star.X, used)
+
+		for _, n := range field.Names {
+			sd.Methods = append(sd.Methods, methodDesc{
+				Name:     n.Name,
+				Args:     exprString(fset, args),
+				ArgsZero: zero,
+				Reply:    exprString(fset, star.X),
+			})
+		}
+	}
+
+	if len(sd.Methods) == 0 {
+		return sd, fmt.Errorf("geerpc-gen: interface %s has no methods", name)
+	}
+	return sd, nil
+}
+
+// flatten 展开参数列表，(a, b T) 展开为两个 T
+func flatten(fl *ast.FieldList) []ast.Expr {
+	var exprs []ast.Expr
+	if fl == nil {
+		return exprs
+	}
+	for _, field := range fl.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+	return exprs
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+// collectPackages 记录类型表达式中引用的包名，例如：time.Duration 中的 time
+func collectPackages(expr ast.Expr, used map[string]bool) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// usedImports 返回源文件中被 used 引用的 import，context 由模板导入
+func usedImports(f *ast.File, used map[string]bool) []string {
+	var imports []string
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used[name] || p == "context" {
+			continue
+		}
+
+		if spec.Name != nil {
+			imports = append(imports, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			imports = append(imports, spec.Path.Value)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// generate 生成服务注册函数和客户端
+func generate(fd *fileDesc) ([]byte, error) {
+	return execute(serviceTemplate, fd)
+}
+
+// generateTest 生成客户端和服务端往返调用的测试
+func generateTest(fd *fileDesc) ([]byte, error) {
+	return execute(testTemplate, fd)
+}
+
+func execute(tmpl *template.Template, fd *fileDesc) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fd); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("geerpc-gen: format generated code: %v", err)
+	}
+	return src, nil
+}
+
+var funcs = template.FuncMap{
+	"lower": func(s string) string { return strings.ToLower(s[:1]) + s[1:] },
+}
+
+var serviceTemplate = template.Must(template.New("service").Funcs(funcs).Parse(`// Code generated by geerpc-gen. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Package}}
+
+import (
+	"context"
+	"{{.Geerpc}}"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $s := .Services}}
+// {{$s.Name}}ServiceName 服务名，方法以 "{{$s.Name}}.Method" 的形式调用
+const {{$s.Name}}ServiceName = "{{$s.Name}}"
+
+// Register{{$s.Name}}Server 将 impl 注册到 server，服务名为 {{$s.Name}}ServiceName
+func Register{{$s.Name}}Server(server *geerpc.Server, impl {{$s.Name}}) error {
+	return server.RegisterName({{$s.Name}}ServiceName, &{{lower $s.Name}}Server{impl: impl})
+}
+
+// {{lower $s.Name}}Server 将 {{$s.Name}} 的方法转换为 geerpc 要求的形式
+type {{lower $s.Name}}Server struct {
+	impl {{$s.Name}}
+}
+{{range $s.Methods}}
+func (s *{{lower $s.Name}}Server) {{.Name}}(ctx context.Context, args {{.Args}}, reply *{{.Reply}}) error {
+	r, err := s.impl.{{.Name}}(ctx, args)
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		*reply = *r
+	}
+	return nil
+}
+{{end}}
+// {{$s.Name}}Client {{$s.Name}} 的客户端，cc 可以是 *geerpc.Client、*geerpc.Pool 或 *xclient.XClient
+type {{$s.Name}}Client struct {
+	cc geerpc.Caller
+}
+
+var _ {{$s.Name}} = (*{{$s.Name}}Client)(nil)
+
+// New{{$s.Name}}Client 创建 {{$s.Name}} 的客户端
+func New{{$s.Name}}Client(cc geerpc.Caller) *{{$s.Name}}Client {
+	return &{{$s.Name}}Client{cc: cc}
+}
+{{range $s.Methods}}
+// {{.Name}} 调用 {{$s.Name}}.{{.Name}}
+func (c *{{$s.Name}}Client) {{.Name}}(ctx context.Context, args {{.Args}}) (*{{.Reply}}, error) {
+	reply := new({{.Reply}})
+	if err := c.cc.Call(ctx, {{$s.Name}}ServiceName+".{{.Name}}", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+{{end}}{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Funcs(funcs).Parse(`// Code generated by geerpc-gen. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Package}}
+
+import (
+	"context"
+	"errors"
+	"{{.Geerpc}}"
+	"net"
+	"strings"
+	"testing"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $s := .Services}}
+// {{lower $s.Name}}TestServer 返回零值或 err 的 {{$s.Name}} 实现
+type {{lower $s.Name}}TestServer struct {
+	err error
+}
+{{range $s.Methods}}
+func (s *{{lower $s.Name}}TestServer) {{.Name}}(ctx context.Context, args {{.Args}}) (*{{.Reply}}, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return new({{.Reply}}), nil
+}
+{{end}}
+// start{{$s.Name}}Server 启动服务并返回连接到该服务的客户端
+func start{{$s.Name}}Server(t *testing.T, impl {{$s.Name}}) *{{$s.Name}}Client {
+	server := geerpc.NewServer()
+	if err := Register{{$s.Name}}Server(server, impl); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+
+	client, err := geerpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return New{{$s.Name}}Client(client)
+}
+
+func Test{{$s.Name}}Client_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ok := start{{$s.Name}}Server(t, &{{lower $s.Name}}TestServer{})
+	failed := start{{$s.Name}}Server(t, &{{lower $s.Name}}TestServer{err: errors.New("{{$s.Name}} failed")})
+{{range $s.Methods}}
+	t.Run("{{.Name}}", func(t *testing.T) {
+		if reply, err := ok.{{.Name}}(ctx, {{.ArgsZero}}); err != nil || reply == nil {
+			t.Fatalf("reply = %v, err = %v", reply, err)
+		}
+		if _, err := failed.{{.Name}}(ctx, {{.ArgsZero}}); err == nil || !strings.Contains(err.Error(), "{{$s.Name}} failed") {
+			t.Fatalf("expect server error, got %v", err)
+		}
+	})
+{{end}}}
+{{end}}`))
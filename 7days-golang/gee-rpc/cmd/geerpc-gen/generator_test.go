@@ -0,0 +1,115 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestGenerate_example 示例中提交的生成代码需要和当前生成器的输出一致
+func TestGenerate_example(t *testing.T) {
+	const dir = "../../example/arith/"
+	src, err := os.ReadFile(dir + "arith.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := parse("arith.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Geerpc = "geerpc"
+
+	for file, gen := range map[string]func(*fileDesc) ([]byte, error){
+		"arith_geerpc.go":      generate,
+		"arith_geerpc_test.go": generateTest,
+	} {
+		code, err := gen(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(dir + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(code, want) {
+			t.Fatalf("%s is out of date, run go generate in example/arith", file)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := `package foo
+
+import (
+	"context"
+	t "time"
+	"net/http"
+)
+
+type Foo interface {
+	Sum(ctx context.Context, args *Args) (*Reply, error)
+	Wait(context.Context, t.Duration) (*Reply, error)
+}
+
+type Bar interface {
+	Get(ctx context.Context, args int) (*http.Header, error)
+}
+
+type notExported interface {
+	Get(ctx context.Context, args int) (*int, error)
+}
+`
+	fd, err := parse("foo.go", []byte(src), []string{"Foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fd.Services) != 1 || len(fd.Services[0].Methods) != 2 {
+		t.Fatalf("unexpected services %+v", fd.Services)
+	}
+	m := fd.Services[0].Methods[1]
+	if m.Args != "t.Duration" || m.ArgsZero != "*new(t.Duration)" || m.Reply != "Reply" {
+		t.Fatalf("unexpected method %+v", m)
+	}
+	if len(fd.Imports) != 1 || fd.Imports[0] != `t "time"` {
+		t.Fatalf("unexpected imports %v", fd.Imports)
+	}
+
+	fd, err = parse("foo.go", []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fd.Services) != 2 || len(fd.Imports) != 2 {
+		t.Fatalf("expect Foo and Bar with 2 imports, got %+v", fd)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name, method, err string
+	}{
+		{"no context", "Sum(args *Args) (*Reply, error)", "must have parameters"},
+		{"too many args", "Sum(ctx context.Context, a, b int) (*Reply, error)", "must have parameters"},
+		{"reply not pointer", "Sum(ctx context.Context, args *Args) (Reply, error)", "must return"},
+		{"no error", "Sum(ctx context.Context, args *Args) (*Reply, bool)", "must return"},
+		{"no results", "Ping(ctx context.Context, args *Args)", "must return"},
+		{"one result", "Ping(ctx context.Context, args *Args) error", "must return"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package foo\n\ntype Foo interface {\n\t" + tt.method + "\n}\n"
+			if _, err := parse("foo.go", []byte(src), nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expect error %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	if _, err := parse("foo.go", []byte("package foo\n"), []string{"Foo"}); err == nil {
+		t.Fatal("expect error for missing interface")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// geerpc-gen 根据 Go 接口定义生成类型安全的服务注册函数和客户端。
+//
+// 接口的每个方法形如：
+//
+//	Method(ctx context.Context, args T1) (*T2, error)
+//
+// 对于接口 Foo，生成 RegisterFooServer(server, impl) 和 FooClient，
+// FooClient 同样实现了 Foo，调用 FooClient.Sum(ctx, args) 即调用远端的 Foo.Sum。
+// 同时生成客户端和服务端往返调用的测试。
+//
+// 用法：
+//
+//	//go:generate go run geerpc/cmd/geerpc-gen -in foo.go
+//
+// 生成 foo_geerpc.go 和 foo_geerpc_test.go。
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	in := flag.String("in", "", "Go source file containing service interfaces")
+	out := flag.String("out", "", "output file, default <in>_geerpc.go")
+	types := flag.String("type", "", "comma-separated interface names, default all exported interfaces")
+	pkg := flag.String("geerpc", "geerpc", "import path of the geerpc package")
+	withTest := flag.Bool("test", true, "also generate round-trip tests in <out>_test.go")
+	flag.Parse()
+
+	log.SetFlags(0)
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(*in, ".go") + "_geerpc.go"
+	}
+
+	src, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	fd, err := parse(*in, src, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fd.Geerpc = *pkg
+
+	code, err := generate(fd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	if *withTest {
+		code, err := generateTest(fd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(strings.TrimSuffix(*out, ".go")+"_test.go", code, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
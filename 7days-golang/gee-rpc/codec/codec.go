@@ -7,11 +7,26 @@ package codec
 import "io"
 
 type Header struct {
-	ServiceMethod string // format "Service.Method"
-	Seq           uint64 // sequence number chosen by client
-	Error         string
+	ServiceMethod string            // format "Service.Method"
+	Seq           uint64            // sequence number chosen by client
+	Error         string            // Error 服务端返回的错误信息
+	Metadata      map[string]string // Metadata 请求的元数据，例如：trace id、鉴权信息、剩余超时时间
+	Flag          Flag              // Flag 帧的类型，普通调用为 FlagNone
 }
 
+// Flag 区分普通调用和流式调用的帧
+type Flag uint8
+
+const (
+	FlagNone       Flag = iota // FlagNone 普通调用的请求和响应
+	FlagStreamOpen             // FlagStreamOpen 客户端打开一个流，body 为方法的参数
+	FlagStreamMsg              // FlagStreamMsg 流中的一条消息，两个方向都可以发送
+	FlagStreamEnd              // FlagStreamEnd 结束流，客户端表示不再发送，服务端表示方法已返回，Error 不为空时表示出错
+	FlagShutdown               // FlagShutdown 服务端即将关闭，客户端不要再发送新的请求
+	FlagOneWay                 // FlagOneWay 单向调用，服务端执行方法但不发送响应
+	FlagStreamCredit           // FlagStreamCredit 流的接收方读取了消息，body 为允许对端再发送的消息数
+)
+
 type Codec interface {
 	io.Closer
 	ReadHeader(*Header) error
@@ -19,7 +34,20 @@ type Codec interface {
 	Write(*Header, any) error
 }
 
-type NewCodecFunc func(io.ReadWriteCloser) *GobCodec
+// Message 一个待发送的消息
+type Message struct {
+	Header *Header
+	Body   any
+}
+
+// BatchCodec 支持一次写入多个消息的 Codec，所有消息只刷新一次缓冲区，frameCodec 的实现都支持
+type BatchCodec interface {
+	Codec
+	WriteBatch(msgs []Message) error
+}
+
+// NewCodecFunc 创建 Codec 的构造函数
+type NewCodecFunc func(io.ReadWriteCloser) Codec
 
 type Type string
 
@@ -30,7 +58,25 @@ const (
 
 var NewCodecFuncMap map[Type]NewCodecFunc
 
+// TypeIDs 握手时用一个字节表示编码类型，新增的编码需要同时注册编号
+var TypeIDs map[Type]byte
+
 func init() {
 	NewCodecFuncMap = make(map[Type]NewCodecFunc)
 	NewCodecFuncMap[GobType] = NewGobCodec
+	NewCodecFuncMap[JsonType] = NewJsonCodec
+
+	TypeIDs = make(map[Type]byte)
+	TypeIDs[GobType] = 1
+	TypeIDs[JsonType] = 2
+}
+
+// TypeByID 根据握手中的编号找到编码类型
+func TypeByID(id byte) (Type, bool) {
+	for t, i := range TypeIDs {
+		if i == id {
+			return t, true
+		}
+	}
+	return "", false
 }
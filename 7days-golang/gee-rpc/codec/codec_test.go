@@ -0,0 +1,70 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"net"
+	"testing"
+)
+
+type args struct{ Num1, Num2 int }
+
+func TestCodecRoundTrip(t *testing.T) {
+	for typ, f := range NewCodecFuncMap {
+		c1, c2 := net.Pipe()
+		w, r := f(c1), f(c2)
+
+		go func() {
+			_ = w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 1}, &args{Num1: 1, Num2: 2})
+			_ = w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 2, Metadata: map[string]string{"trace-id": "abc"}}, &args{Num1: 3, Num2: 4})
+		}()
+
+		var h Header
+		if err := r.ReadHeader(&h); err != nil || h.ServiceMethod != "Foo.Sum" || h.Seq != 1 {
+			t.Fatalf("%s: read header failed, header: %v, err: %v", typ, h, err)
+		}
+		// 丢弃第一个 body，不影响后续读取
+		if err := r.ReadBody(nil); err != nil {
+			t.Fatalf("%s: discard body failed: %v", typ, err)
+		}
+
+		var body args
+		h = Header{}
+		if err := r.ReadHeader(&h); err != nil || h.Seq != 2 || h.Metadata["trace-id"] != "abc" {
+			t.Fatalf("%s: read header failed, header: %v, err: %v", typ, h, err)
+		}
+		if err := r.ReadBody(&body); err != nil || body.Num1 != 3 || body.Num2 != 4 {
+			t.Fatalf("%s: read body failed, body: %v, err: %v", typ, body, err)
+		}
+
+		_ = w.Close()
+		_ = r.Close()
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	for typ, f := range NewCodecFuncMap {
+		conn := new(bufConn)
+		cc := f(conn)
+		msgs := []Message{
+			{Header: &Header{ServiceMethod: "Foo.Sum", Seq: 1}, Body: &args{Num1: 1}},
+			{Header: &Header{ServiceMethod: "Foo.Sum", Seq: 2}, Body: &args{Num1: 2}},
+		}
+		if err := cc.(BatchCodec).WriteBatch(msgs); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i <= 2; i++ {
+			var h Header
+			var body args
+			if err := cc.ReadHeader(&h); err != nil || h.Seq != uint64(i) {
+				t.Fatalf("%s: read header failed, header: %v, err: %v", typ, h, err)
+			}
+			if err := cc.ReadBody(&body); err != nil || body.Num1 != i {
+				t.Fatalf("%s: read body failed, body: %v, err: %v", typ, body, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultCompressThreshold 默认的压缩阈值，小于该长度的帧不压缩
+const DefaultCompressThreshold = 1024
+
+// CompressType 帧的压缩算法，握手时协商
+type CompressType string
+
+const (
+	NoCompress   CompressType = ""     // NoCompress 不压缩
+	GzipCompress CompressType = "gzip" // GzipCompress gzip 压缩，压缩率高
+	LZCompress   CompressType = "lz"   // LZCompress 类似 snappy 的 LZ77 压缩，速度快，压缩率较低
+)
+
+// Compressor 压缩算法，需要支持并发调用
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// CompressibleCodec 支持按帧压缩的 Codec，frameCodec 的实现都支持。
+// 长度不小于 threshold 的帧使用 c 压缩，收到的压缩帧使用 c 解压。
+type CompressibleCodec interface {
+	Codec
+	SetCompressor(c Compressor, threshold int)
+}
+
+var Compressors map[CompressType]Compressor
+
+// CompressIDs 握手时用一个字节表示压缩算法，0 表示不压缩，新增的算法需要同时注册编号
+var CompressIDs map[CompressType]byte
+
+func init() {
+	Compressors = make(map[CompressType]Compressor)
+	Compressors[GzipCompress] = gzipCompressor{}
+	Compressors[LZCompress] = lzCompressor{}
+
+	CompressIDs = make(map[CompressType]byte)
+	CompressIDs[NoCompress] = 0
+	CompressIDs[GzipCompress] = 1
+	CompressIDs[LZCompress] = 2
+}
+
+// CompressTypeByID 根据握手中的编号找到压缩算法
+func CompressTypeByID(id byte) (CompressType, bool) {
+	for t, i := range CompressIDs {
+		if i == id {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	// 解压后的长度同样不能超过 MaxFrameSize
+	out, err := io.ReadAll(io.LimitReader(r, MaxFrameSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxFrameSize {
+		return nil, fmt.Errorf("%w: decompressed size exceeds %d bytes", ErrFrameTooLarge, MaxFrameSize)
+	}
+	return out, nil
+}
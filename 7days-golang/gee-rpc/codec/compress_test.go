@@ -0,0 +1,103 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCompressorRoundTrip(t *testing.T) {
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	inputs := map[string][]byte{
+		"empty":      {},
+		"short":      []byte("abc"),
+		"repetitive": []byte(strings.Repeat("geerpc ", 2000)),
+		"overlap":    bytes.Repeat([]byte{'a'}, 100),
+		"random":     random,
+	}
+
+	for typ, c := range Compressors {
+		for name, in := range inputs {
+			compressed, err := c.Compress(in)
+			if err != nil {
+				t.Fatalf("%s/%s: compress: %v", typ, name, err)
+			}
+			out, err := c.Decompress(compressed)
+			if err != nil || !bytes.Equal(in, out) {
+				t.Fatalf("%s/%s: round trip failed, err: %v", typ, name, err)
+			}
+			if name == "repetitive" && len(compressed) > len(in)/10 {
+				t.Fatalf("%s: repetitive input compressed to %d bytes", typ, len(compressed))
+			}
+		}
+	}
+}
+
+func TestLZ_corrupt(t *testing.T) {
+	c := lzCompressor{}
+	for _, data := range [][]byte{
+		{},
+		{5, lzLiteral, 10, 'a'},        // literal 超出数据
+		{5, lzCopy, 1, 5},              // copy 时还没有数据
+		{2, lzLiteral, 1, 'a'},         // 长度不符
+		{1, 9, 0},                      // 未知的 tag
+		{1, lzLiteral, 1, 'a', 1, 1},   // 超出声明的长度
+		{0xff, 0xff, 0xff, 0xff, 0x7f}, // 长度过大
+	} {
+		if _, err := c.Decompress(data); err == nil {
+			t.Fatalf("expect error for %v", data)
+		}
+	}
+}
+
+// bufConn 写入的数据可以再读出来，用于检查写到连接上的字节
+type bufConn struct {
+	bytes.Buffer
+}
+
+func (c *bufConn) Close() error { return nil }
+
+func TestFrameCompression(t *testing.T) {
+	big := strings.Repeat("bulk reply ", 1000)
+	for typ, f := range NewCodecFuncMap {
+		for ctyp, compressor := range Compressors {
+			conn := new(bufConn)
+			cc := f(conn).(CompressibleCodec)
+			cc.SetCompressor(compressor, 0)
+
+			// header 小于阈值不压缩，body 大于阈值压缩
+			if err := cc.Write(&Header{ServiceMethod: "Foo.Bulk", Seq: 1}, big); err != nil {
+				t.Fatal(err)
+			}
+			if conn.Len() > len(big)/5 {
+				t.Fatalf("%s/%s: expect compressed frame, wrote %d bytes", typ, ctyp, conn.Len())
+			}
+
+			var h Header
+			var body string
+			if err := cc.ReadHeader(&h); err != nil || h.Seq != 1 {
+				t.Fatalf("%s/%s: read header failed, header: %v, err: %v", typ, ctyp, h, err)
+			}
+			if err := cc.ReadBody(&body); err != nil || body != big {
+				t.Fatalf("%s/%s: read body failed, err: %v", typ, ctyp, err)
+			}
+		}
+
+		// 没有协商压缩时不接受压缩的帧
+		conn := new(bufConn)
+		w := f(conn).(CompressibleCodec)
+		w.SetCompressor(Compressors[GzipCompress], 1)
+		_ = w.Write(&Header{Seq: 1}, big)
+		var h Header
+		if err := f(conn).ReadHeader(&h); err == nil {
+			t.Fatalf("%s: expect error for unexpected compressed frame", typ)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// MaxFrameSize 单个帧允许的最大长度，避免错误的长度导致分配过大的内存
+const MaxFrameSize = 64 << 20
+
+// compressedBit 长度的最高位，表示帧的数据经过压缩
+const compressedBit = 1 << 31
+
+var ErrFrameTooLarge = errors.New("codec: frame too large")
+
+// FrameSize 返回 b 开头的完整帧的长度（包括长度前缀），数据不完整时返回 false。
+// 用于在 Codec 之外按帧拆分数据，例如 geerpctest 的故障注入。
+func FrameSize(b []byte) (int, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	n := 4 + int(binary.BigEndian.Uint32(b)&^compressedBit)
+	return n, len(b) >= n
+}
+
+// frameCodec 将 header 和 body 分别编码为长度前缀的帧：
+//
+//	| compressed 1 bit | length 31 bits (big endian) | data ... |
+//
+// 每个帧独立编解码，读取失败或者不需要的 body 可以整帧跳过，不会影响后续的帧。
+// 设置了 Compressor 时，长度不小于阈值的帧压缩后发送，并标记 compressed。
+type frameCodec struct {
+	name      string // name 编码名称，用于日志
+	conn      io.ReadWriteCloser
+	r         *bufio.Reader
+	buf       *bufio.Writer
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+
+	compressor Compressor // compressor 握手时协商的压缩算法，nil 表示不压缩
+	threshold  int        // threshold 压缩阈值
+}
+
+func newFrameCodec(name string, conn io.ReadWriteCloser, marshal func(any) ([]byte, error), unmarshal func([]byte, any) error) *frameCodec {
+	return &frameCodec{
+		name:      name,
+		conn:      conn,
+		r:         bufio.NewReader(conn),
+		buf:       bufio.NewWriter(conn),
+		marshal:   marshal,
+		unmarshal: unmarshal,
+	}
+}
+
+// SetCompressor 设置压缩算法，需要在读写前调用，threshold 不大于 0 时使用 DefaultCompressThreshold
+func (c *frameCodec) SetCompressor(compressor Compressor, threshold int) {
+	if threshold <= 0 {
+		threshold = DefaultCompressThreshold
+	}
+	c.compressor = compressor
+	c.threshold = threshold
+}
+
+func (c *frameCodec) ReadHeader(h *Header) error {
+	data, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	return c.unmarshal(data, h)
+}
+
+// ReadBody body 为 nil 时跳过整个帧
+func (c *frameCodec) ReadBody(body any) error {
+	if body == nil {
+		return c.discardFrame()
+	}
+
+	data, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	return c.unmarshal(data, body)
+}
+
+func (c *frameCodec) Close() error {
+	return c.conn.Close()
+}
+
+// Write 编码请求的 Header 和 body 数据，出错时关闭连接
+func (c *frameCodec) Write(h *Header, body any) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+
+	return c.writeMessage(h, body)
+}
+
+// WriteBatch 依次编码多个消息，最后只刷新一次缓冲区，出错时关闭连接
+func (c *frameCodec) WriteBatch(msgs []Message) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+
+	for _, msg := range msgs {
+		if err = c.writeMessage(msg.Header, msg.Body); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeMessage 将 Header 和 body 写入缓冲区，不刷新
+func (c *frameCodec) writeMessage(h *Header, body any) error {
+	if err := c.writeFrame(h); err != nil {
+		log.Printf("rpc: %s error encoding header: %v", c.name, err)
+		return err
+	}
+
+	if err := c.writeFrame(body); err != nil {
+		log.Printf("rpc: %s error encoding body: %v", c.name, err)
+		return err
+	}
+	return nil
+}
+
+// writeFrame 编码 v 并写入一个帧
+func (c *frameCodec) writeFrame(v any) error {
+	data, err := c.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var flag uint32
+	if c.compressor != nil && len(data) >= c.threshold {
+		if data, err = c.compressor.Compress(data); err != nil {
+			return err
+		}
+		flag = compressedBit
+	}
+	if len(data) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], flag|uint32(len(data)))
+	if _, err = c.buf.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err = c.buf.Write(data)
+	return err
+}
+
+// readLength 读取帧的长度以及是否经过压缩
+func (c *frameCodec) readLength() (n int, compressed bool, err error) {
+	var prefix [4]byte
+	if _, err = io.ReadFull(c.r, prefix[:]); err != nil {
+		return 0, false, err
+	}
+
+	l := binary.BigEndian.Uint32(prefix[:])
+	compressed, l = l&compressedBit != 0, l&^compressedBit
+	if l > MaxFrameSize {
+		return 0, false, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, l)
+	}
+	return int(l), compressed, nil
+}
+
+// readFrame 读取一个完整的帧，压缩的帧返回解压后的数据
+func (c *frameCodec) readFrame() ([]byte, error) {
+	n, compressed, err := c.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	if _, err = io.ReadFull(c.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if compressed {
+		if c.compressor == nil {
+			return nil, errors.New("codec: received compressed frame without negotiated compression")
+		}
+		return c.compressor.Decompress(data)
+	}
+	return data, nil
+}
+
+// discardFrame 跳过一个帧
+func (c *frameCodec) discardFrame() error {
+	n, _, err := c.readLength()
+	if err != nil {
+		return err
+	}
+	_, err = c.r.Discard(n)
+	return err
+}
@@ -5,65 +5,35 @@
 package codec
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/gob"
 	"io"
-	"log"
 )
 
+// GobCodec 使用 gob 编码每个帧。
+// 每个帧使用独立的 Encoder，类型信息随帧发送，这样跳过某个帧不会影响后续帧的解码。
 type GobCodec struct {
-	conn io.ReadWriteCloser
-	buf  *bufio.Writer
-	dec  *gob.Decoder
-	enc  *gob.Encoder
+	*frameCodec
 }
 
-// 检查 GobCodec 是否实现了 Codec
-var _ Codec = (*GobCodec)(nil)
-
-func NewGobCodec(conn io.ReadWriteCloser) *GobCodec {
-	buf := bufio.NewWriter(conn)
-	return &GobCodec{
-		conn: conn,
-		buf:  buf,
-		dec:  gob.NewDecoder(conn),
-		enc:  gob.NewEncoder(buf),
-	}
-}
-
-func (c *GobCodec) ReadHeader(h *Header) error {
-	return c.dec.Decode(h)
-}
-
-func (c *GobCodec) ReadBody(body any) error {
-	return c.dec.Decode(body)
-}
+// 检查 GobCodec 是否实现了 Codec，并支持压缩和批量写入
+var (
+	_ CompressibleCodec = (*GobCodec)(nil)
+	_ BatchCodec        = (*GobCodec)(nil)
+)
 
-func (c *GobCodec) Close() error {
-	return c.conn.Close()
+func NewGobCodec(conn io.ReadWriteCloser) Codec {
+	return &GobCodec{newFrameCodec("gob", conn, gobMarshal, gobUnmarshal)}
 }
 
-// Write gob 编码请求的 Header 和 body 数据
-//
-// ----------------------------------------------------------------------
-// 这里的返回值介绍：
-// 函数定义时可以给返回值命名，并在函数体中直接使用这些变量，最后通过`return`关键字返回。
-func (c *GobCodec) Write(h *Header, body any) (err error) {
-	defer func() {
-		_ = c.buf.Flush()
-		if err != nil {
-			_ = c.Close()
-		}
-	}()
-
-	if err = c.enc.Encode(h); err != nil {
-		log.Println("rpc: god error encoding body:", err)
-		return
+func gobMarshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	if err = c.enc.Encode(body); err != nil {
-		log.Println("rpc: god error encoding body:", err)
-		return
-	}
-	return
+func gobUnmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
 }
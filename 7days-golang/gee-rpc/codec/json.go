@@ -0,0 +1,25 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JsonCodec 使用 json 编码每个帧，方便非 Go 的程序调用
+type JsonCodec struct {
+	*frameCodec
+}
+
+// 检查 JsonCodec 是否实现了 Codec，并支持压缩和批量写入
+var (
+	_ CompressibleCodec = (*JsonCodec)(nil)
+	_ BatchCodec        = (*JsonCodec)(nil)
+)
+
+func NewJsonCodec(conn io.ReadWriteCloser) Codec {
+	return &JsonCodec{newFrameCodec("json", conn, json.Marshal, json.Unmarshal)}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// lzCompressor 类似 snappy 的 LZ77 压缩，只查找一次 4 字节的匹配，不做熵编码。
+// 格式为原始长度加上一串元素：
+//
+//	| length uvarint | element ... |
+//
+// 元素分为两种：
+//
+//	literal: | 0 | n uvarint | n bytes |
+//	copy:    | 1 | offset uvarint | n uvarint |   从已解压数据末尾往前 offset 处复制 n 字节，可以重叠
+type lzCompressor struct{}
+
+const (
+	lzLiteral = 0
+	lzCopy    = 1
+
+	lzMinMatch  = 4
+	lzTableBits = 14
+)
+
+var errCorruptLZ = errors.New("codec: corrupt lz data")
+
+func (lzCompressor) Compress(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(src)/2+binary.MaxVarintLen64)
+	dst = appendUvarint(dst, uint64(len(src)))
+
+	// table 记录 4 字节序列最后出现的位置加 1，0 表示没有出现过
+	var table [1 << lzTableBits]int32
+	lit := 0
+	for i := 0; i+lzMinMatch <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 0x1e35a7bd) >> (32 - lzTableBits)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != seq {
+			i++
+			continue
+		}
+
+		n := lzMinMatch
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		dst = appendLiteral(dst, src[lit:i])
+		dst = append(dst, lzCopy)
+		dst = appendUvarint(dst, uint64(i-cand))
+		dst = appendUvarint(dst, uint64(n))
+		i += n
+		lit = i
+	}
+	return appendLiteral(dst, src[lit:]), nil
+}
+
+func appendLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	dst = append(dst, lzLiteral)
+	dst = appendUvarint(dst, uint64(len(lit)))
+	return append(dst, lit...)
+}
+
+// appendUvarint 同 binary.AppendUvarint，go 1.18 中没有该函数
+func appendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(dst, buf[:n]...)
+}
+
+func (lzCompressor) Decompress(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errCorruptLZ
+	}
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: decompressed size %d", ErrFrameTooLarge, size)
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		src = src[1:]
+
+		switch tag {
+		case lzLiteral:
+			l, n := binary.Uvarint(src)
+			if n <= 0 || l > uint64(len(src)-n) || uint64(len(dst))+l > size {
+				return nil, errCorruptLZ
+			}
+			dst = append(dst, src[n:n+int(l)]...)
+			src = src[n+int(l):]
+		case lzCopy:
+			offset, n1 := binary.Uvarint(src)
+			if n1 <= 0 {
+				return nil, errCorruptLZ
+			}
+			l, n2 := binary.Uvarint(src[n1:])
+			if n2 <= 0 || offset == 0 || offset > uint64(len(dst)) || uint64(len(dst))+l > size {
+				return nil, errCorruptLZ
+			}
+			// 逐字节复制，offset 小于 l 时复制的是刚刚写入的数据
+			start := len(dst) - int(offset)
+			for k := 0; k < int(l); k++ {
+				dst = append(dst, dst[start+k])
+			}
+			src = src[n1+n2:]
+		default:
+			return nil, errCorruptLZ
+		}
+	}
+
+	if uint64(len(dst)) != size {
+		return nil, errCorruptLZ
+	}
+	return dst, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package arith geerpc-gen 的示例，arith_geerpc.go 由 Arith 接口生成
+package arith
+
+//go:generate go run geerpc/cmd/geerpc-gen -in arith.go
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type Args struct {
+	Num1, Num2 int
+}
+
+type Reply struct {
+	Num int
+}
+
+type Quotient struct {
+	Quo, Rem int
+}
+
+// Arith 算术服务
+type Arith interface {
+	Sum(ctx context.Context, args *Args) (*Reply, error)
+	Div(ctx context.Context, args Args) (*Quotient, error)
+	Sleep(ctx context.Context, d time.Duration) (*Reply, error)
+}
+
+// Service Arith 的实现
+type Service struct{}
+
+var _ Arith = Service{}
+
+func (Service) Sum(ctx context.Context, args *Args) (*Reply, error) {
+	return &Reply{Num: args.Num1 + args.Num2}, nil
+}
+
+func (Service) Div(ctx context.Context, args Args) (*Quotient, error) {
+	if args.Num2 == 0 {
+		return nil, errors.New("divide by zero")
+	}
+	return &Quotient{Quo: args.Num1 / args.Num2, Rem: args.Num1 % args.Num2}, nil
+}
+
+func (Service) Sleep(ctx context.Context, d time.Duration) (*Reply, error) {
+	select {
+	case <-time.After(d):
+		return &Reply{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
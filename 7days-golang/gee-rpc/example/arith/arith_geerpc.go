@@ -0,0 +1,95 @@
+// Code generated by geerpc-gen. DO NOT EDIT.
+// source: arith.go
+
+package arith
+
+import (
+	"context"
+	"geerpc"
+	"time"
+)
+
+// ArithServiceName 服务名，方法以 "Arith.Method" 的形式调用
+const ArithServiceName = "Arith"
+
+// RegisterArithServer 将 impl 注册到 server，服务名为 ArithServiceName
+func RegisterArithServer(server *geerpc.Server, impl Arith) error {
+	return server.RegisterName(ArithServiceName, &arithServer{impl: impl})
+}
+
+// arithServer 将 Arith 的方法转换为 geerpc 要求的形式
+type arithServer struct {
+	impl Arith
+}
+
+func (s *arithServer) Sum(ctx context.Context, args *Args, reply *Reply) error {
+	r, err := s.impl.Sum(ctx, args)
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		*reply = *r
+	}
+	return nil
+}
+
+func (s *arithServer) Div(ctx context.Context, args Args, reply *Quotient) error {
+	r, err := s.impl.Div(ctx, args)
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		*reply = *r
+	}
+	return nil
+}
+
+func (s *arithServer) Sleep(ctx context.Context, args time.Duration, reply *Reply) error {
+	r, err := s.impl.Sleep(ctx, args)
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		*reply = *r
+	}
+	return nil
+}
+
+// ArithClient Arith 的客户端，cc 可以是 *geerpc.Client、*geerpc.Pool 或 *xclient.XClient
+type ArithClient struct {
+	cc geerpc.Caller
+}
+
+var _ Arith = (*ArithClient)(nil)
+
+// NewArithClient 创建 Arith 的客户端
+func NewArithClient(cc geerpc.Caller) *ArithClient {
+	return &ArithClient{cc: cc}
+}
+
+// Sum 调用 Arith.Sum
+func (c *ArithClient) Sum(ctx context.Context, args *Args) (*Reply, error) {
+	reply := new(Reply)
+	if err := c.cc.Call(ctx, ArithServiceName+".Sum", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Div 调用 Arith.Div
+func (c *ArithClient) Div(ctx context.Context, args Args) (*Quotient, error) {
+	reply := new(Quotient)
+	if err := c.cc.Call(ctx, ArithServiceName+".Div", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Sleep 调用 Arith.Sleep
+func (c *ArithClient) Sleep(ctx context.Context, args time.Duration) (*Reply, error) {
+	reply := new(Reply)
+	if err := c.cc.Call(ctx, ArithServiceName+".Sleep", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,95 @@
+// Code generated by geerpc-gen. DO NOT EDIT.
+// source: arith.go
+
+package arith
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// arithTestServer 返回零值或 err 的 Arith 实现
+type arithTestServer struct {
+	err error
+}
+
+func (s *arithTestServer) Sum(ctx context.Context, args *Args) (*Reply, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return new(Reply), nil
+}
+
+func (s *arithTestServer) Div(ctx context.Context, args Args) (*Quotient, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return new(Quotient), nil
+}
+
+func (s *arithTestServer) Sleep(ctx context.Context, args time.Duration) (*Reply, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return new(Reply), nil
+}
+
+// startArithServer 启动服务并返回连接到该服务的客户端
+func startArithServer(t *testing.T, impl Arith) *ArithClient {
+	server := geerpc.NewServer()
+	if err := RegisterArithServer(server, impl); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+
+	client, err := geerpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return NewArithClient(client)
+}
+
+func TestArithClient_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ok := startArithServer(t, &arithTestServer{})
+	failed := startArithServer(t, &arithTestServer{err: errors.New("Arith failed")})
+
+	t.Run("Sum", func(t *testing.T) {
+		if reply, err := ok.Sum(ctx, new(Args)); err != nil || reply == nil {
+			t.Fatalf("reply = %v, err = %v", reply, err)
+		}
+		if _, err := failed.Sum(ctx, new(Args)); err == nil || !strings.Contains(err.Error(), "Arith failed") {
+			t.Fatalf("expect server error, got %v", err)
+		}
+	})
+
+	t.Run("Div", func(t *testing.T) {
+		if reply, err := ok.Div(ctx, *new(Args)); err != nil || reply == nil {
+			t.Fatalf("reply = %v, err = %v", reply, err)
+		}
+		if _, err := failed.Div(ctx, *new(Args)); err == nil || !strings.Contains(err.Error(), "Arith failed") {
+			t.Fatalf("expect server error, got %v", err)
+		}
+	})
+
+	t.Run("Sleep", func(t *testing.T) {
+		if reply, err := ok.Sleep(ctx, *new(time.Duration)); err != nil || reply == nil {
+			t.Fatalf("reply = %v, err = %v", reply, err)
+		}
+		if _, err := failed.Sleep(ctx, *new(time.Duration)); err == nil || !strings.Contains(err.Error(), "Arith failed") {
+			t.Fatalf("expect server error, got %v", err)
+		}
+	})
+}
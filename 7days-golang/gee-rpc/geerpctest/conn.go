@@ -0,0 +1,131 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpctest
+
+import (
+	"bytes"
+	"errors"
+	"geerpc"
+	"geerpc/codec"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var errReset = errors.New("geerpctest: connection reset by fault injection")
+
+// faultConn 服务端一侧的连接，按 geerpc 的帧格式拆分出每个响应，根据响应头匹配注入的故障。
+// 响应由 header 和 body 两个长度前缀的帧组成，握手的前导和回复不受影响；
+// 只有普通调用的响应（codec.FlagNone）会匹配故障，流中的帧和关闭通知直接发送。
+type faultConn struct {
+	net.Conn
+	s *Server
+
+	preamble []byte // preamble 客户端发送的前导，用于确定编码和压缩算法
+
+	mu        sync.Mutex
+	handshook bool   // handshook 已经发送了握手的回复
+	buf       []byte // buf 还没有凑成一个完整响应的数据
+	closeOnce sync.Once
+}
+
+func (c *faultConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if need := geerpc.PreambleSize - len(c.preamble); need > 0 {
+		if need > n {
+			need = n
+		}
+		c.preamble = append(c.preamble, p[:need]...)
+	}
+	return n, err
+}
+
+func (c *faultConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 第一次写入是握手的回复
+	if !c.handshook {
+		c.handshook = true
+		return c.Conn.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	for {
+		hn, ok := codec.FrameSize(c.buf)
+		if !ok {
+			break
+		}
+		bn, ok := codec.FrameSize(c.buf[hn:])
+		if !ok {
+			break
+		}
+
+		if err := c.send(c.buf[:hn+bn], c.buf[:hn]); err != nil {
+			return 0, err
+		}
+		c.buf = c.buf[hn+bn:]
+	}
+	return len(p), nil
+}
+
+// send 根据匹配的故障发送一个响应
+func (c *faultConn) send(msg, header []byte) error {
+	var f *Fault
+	if h := c.readHeader(header); h != nil && h.Flag == codec.FlagNone {
+		f = c.s.match(h.ServiceMethod)
+	}
+	if f == nil {
+		_, err := c.Conn.Write(msg)
+		return err
+	}
+
+	switch {
+	case f.Reset:
+		_ = c.Close()
+		return errReset
+	case f.Drop:
+		return nil
+	}
+
+	time.Sleep(f.Delay)
+	_, err := c.Conn.Write(msg)
+	return err
+}
+
+// readHeader 使用握手时协商的编码和压缩算法解析响应头，无法解析时返回 nil
+func (c *faultConn) readHeader(frame []byte) *codec.Header {
+	opt, err := geerpc.ParsePreamble(c.preamble)
+	if err != nil {
+		return nil
+	}
+
+	cc := codec.NewCodecFuncMap[opt.CodecType](readOnly{bytes.NewReader(frame)})
+	if opt.Compress != codec.NoCompress {
+		if compressible, ok := cc.(codec.CompressibleCodec); ok {
+			compressible.SetCompressor(codec.Compressors[opt.Compress], 0)
+		}
+	}
+
+	var h codec.Header
+	if err := cc.ReadHeader(&h); err != nil {
+		return nil
+	}
+	return &h
+}
+
+func (c *faultConn) Close() error {
+	c.closeOnce.Do(func() { c.s.trackConn(c, false) })
+	return c.Conn.Close()
+}
+
+// readOnly 只用于读取的 io.ReadWriteCloser
+type readOnly struct {
+	io.Reader
+}
+
+func (readOnly) Write(p []byte) (int, error) { return len(p), nil }
+func (readOnly) Close() error                { return nil }
@@ -0,0 +1,171 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package geerpctest 测试 geerpc 服务的工具。
+// 服务运行在 geerpc.InMemoryListener 上，不占用端口，也不需要 sleep 等待服务启动；
+// 可以向服务端注入故障，例如：延迟响应、丢弃响应、断开连接。
+//
+//	s := geerpctest.NewServer(t, new(Foo))
+//	client := s.Client()
+//	s.Inject(geerpctest.Fault{ServiceMethod: "Foo.Sum", Drop: true, Times: 1})
+package geerpctest
+
+import (
+	"context"
+	"geerpc"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fault 注入服务端的故障，作用于服务端发送的普通调用的响应，流中的帧和关闭通知不受影响
+type Fault struct {
+	ServiceMethod string        // ServiceMethod 匹配的方法，为空时匹配所有普通调用的响应
+	Delay         time.Duration // Delay 延迟发送响应，同一个连接上之后的响应也会被推迟
+	Drop          bool          // Drop 丢弃响应，方法仍然会执行
+	Reset         bool          // Reset 不发送响应，直接断开连接
+	Times         int           // Times 生效的次数，0 表示一直生效
+}
+
+// fault 注入的故障及剩余的次数
+type fault struct {
+	Fault
+	remaining int
+}
+
+// Server 运行在内存中的 geerpc.Server
+type Server struct {
+	*geerpc.Server
+
+	t testing.TB
+	l *geerpc.InMemoryListener
+
+	mu     sync.Mutex
+	faults []*fault
+	conns  map[*faultConn]struct{}
+}
+
+// NewServer 注册 rcvrs 并启动服务，测试结束时关闭服务和所有连接
+func NewServer(t testing.TB, rcvrs ...any) *Server {
+	t.Helper()
+	s := &Server{
+		Server: geerpc.NewServer(),
+		t:      t,
+		l:      geerpc.NewInMemoryListener(),
+		conns:  make(map[*faultConn]struct{}),
+	}
+	for _, rcvr := range rcvrs {
+		if err := s.Register(rcvr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go s.Accept(&listener{InMemoryListener: s.l, s: s})
+	t.Cleanup(s.close)
+	return s
+}
+
+// Client 返回连接到服务的 Client，测试结束时关闭
+func (s *Server) Client(opts ...*geerpc.Option) *geerpc.Client {
+	s.t.Helper()
+	client, err := s.l.DialClient(opts...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// Inject 注入故障，多个故障同时匹配时先注入的生效。返回的函数用于移除故障。
+func (s *Server) Inject(f Fault) (remove func()) {
+	ft := &fault{Fault: f, remaining: f.Times}
+	s.mu.Lock()
+	s.faults = append(s.faults, ft)
+	s.mu.Unlock()
+
+	return func() { s.removeFault(ft) }
+}
+
+func (s *Server) removeFault(ft *fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f == ft {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// match 返回匹配方法的故障，并减少剩余次数
+func (s *Server) match(serviceMethod string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f.ServiceMethod != "" && f.ServiceMethod != serviceMethod {
+			continue
+		}
+		if f.Times > 0 {
+			if f.remaining--; f.remaining == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &f.Fault
+	}
+	return nil
+}
+
+// ResetConns 断开服务端所有的连接
+func (s *Server) ResetConns() {
+	s.mu.Lock()
+	conns := make([]*faultConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+}
+
+// close 关闭服务，有未完成的请求时等待 1 秒后强制断开
+func (s *Server) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.Shutdown(ctx)
+	s.ResetConns()
+}
+
+// trackConn 记录或移除连接
+func (s *Server) trackConn(c *faultConn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if add {
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+}
+
+// listener 将 Accept 返回的连接包装为 faultConn
+type listener struct {
+	*geerpc.InMemoryListener
+	s *Server
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.InMemoryListener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &faultConn{Conn: conn, s: l.s}
+	l.s.trackConn(c, true)
+	return c, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpctest
+
+import (
+	"context"
+	"geerpc"
+	"geerpc/codec"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type Echo struct {
+	calls int64
+}
+
+func (e *Echo) Say(msg string, reply *string) error {
+	atomic.AddInt64(&e.calls, 1)
+	*reply = msg
+	return nil
+}
+
+func (e *Echo) Upper(msg string, reply *string) error {
+	*reply = strings.ToUpper(msg)
+	return nil
+}
+
+// Repeat 把 msg 发送 3 次
+func (e *Echo) Repeat(msg string, stream *geerpc.Stream) error {
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func say(t *testing.T, client *geerpc.Client, timeout time.Duration) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var reply string
+	err := client.Call(ctx, "Echo.Say", "hello", &reply)
+	return reply, err
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(t, new(Echo))
+
+	opts := []*geerpc.Option{
+		nil,
+		{CodecType: codec.JsonType},
+		{Compress: codec.GzipCompress, CompressThreshold: 1},
+	}
+	for _, opt := range opts {
+		if reply, err := say(t, s.Client(opt), time.Second); err != nil || reply != "hello" {
+			t.Fatalf("reply = %q, err = %v", reply, err)
+		}
+	}
+}
+
+func TestServer_Inject(t *testing.T) {
+	echo := new(Echo)
+	s := NewServer(t, echo)
+
+	// 每种故障都在 Echo.Say 上生效一次，不影响其他方法
+	for _, opt := range []*geerpc.Option{nil, {CodecType: codec.JsonType, Compress: codec.LZCompress, CompressThreshold: 1}} {
+		client := s.Client(opt)
+
+		s.Inject(Fault{ServiceMethod: "Echo.Say", Delay: time.Millisecond * 100, Times: 1})
+		start := time.Now()
+		if _, err := say(t, client, time.Second); err != nil || time.Since(start) < time.Millisecond*100 {
+			t.Fatalf("expect delayed reply, got err %v after %s", err, time.Since(start))
+		}
+
+		s.Inject(Fault{ServiceMethod: "Echo.Say", Drop: true, Times: 1})
+		var upper string
+		if err := client.Call(context.Background(), "Echo.Upper", "a", &upper); err != nil || upper != "A" {
+			t.Fatalf("expect other methods unaffected, got %q, err: %v", upper, err)
+		}
+		if _, err := say(t, client, time.Millisecond*100); err != context.DeadlineExceeded {
+			t.Fatalf("expect dropped reply, got %v", err)
+		}
+		if reply, err := say(t, client, time.Second); err != nil || reply != "hello" {
+			t.Fatalf("expect fault removed after 1 time, got %q, err: %v", reply, err)
+		}
+
+		s.Inject(Fault{Reset: true, Times: 1})
+		if _, err := say(t, client, time.Second); err == nil || client.IsAvailable() {
+			t.Fatalf("expect connection reset, got %v", err)
+		}
+	}
+
+	// 丢弃的响应对应的方法仍然执行了
+	if calls := atomic.LoadInt64(&echo.calls); calls != 8 {
+		t.Fatalf("expect 8 calls executed, got %d", calls)
+	}
+
+	remove := s.Inject(Fault{Drop: true})
+	client := s.Client()
+	if _, err := say(t, client, time.Millisecond*50); err != context.DeadlineExceeded {
+		t.Fatalf("expect dropped reply, got %v", err)
+	}
+	remove()
+	if _, err := say(t, client, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_ResetConns(t *testing.T) {
+	s := NewServer(t, new(Echo))
+	client := s.Client()
+	if _, err := say(t, client, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	s.ResetConns()
+	if _, err := say(t, client, time.Second); err == nil {
+		t.Fatal("expect error after ResetConns")
+	}
+	if _, err := say(t, s.Client(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_InjectSkipsStreams(t *testing.T) {
+	s := NewServer(t, new(Echo))
+	s.Inject(Fault{Drop: true})
+	client := s.Client()
+
+	// 匹配所有方法的故障只作用于普通调用，流中的帧不受影响
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stream, err := client.NewStream(ctx, "Echo.Repeat", "hi", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.CloseSend()
+	for i := 0; i < 3; i++ {
+		var msg string
+		if err := stream.Recv(&msg); err != nil || msg != "hi" {
+			t.Fatalf("expect stream message, got %q, err: %v", msg, err)
+		}
+	}
+	if err := stream.Recv(new(string)); err != io.EOF {
+		t.Fatalf("expect io.EOF, got %v", err)
+	}
+
+	if _, err := say(t, client, time.Millisecond*50); err != context.DeadlineExceeded {
+		t.Fatalf("expect dropped reply, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var ErrListenerClosed = errors.New("rpc: in-memory listener closed")
+
+// InMemoryListener 内存中的 net.Listener，Dial 通过 net.Pipe 建立连接，不占用端口，
+// 用于测试或者同一个进程内的调用：
+//
+//	l := geerpc.NewInMemoryListener()
+//	go server.Accept(l)
+//	client, err := l.DialClient()
+type InMemoryListener struct {
+	conns chan net.Conn // conns Dial 创建的服务端一侧的连接，等待 Accept
+	done  chan struct{} // done Close 时关闭
+	once  sync.Once
+}
+
+var _ net.Listener = (*InMemoryListener)(nil)
+
+// NewInMemoryListener 创建 InMemoryListener
+func NewInMemoryListener() *InMemoryListener {
+	return &InMemoryListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept 等待 Dial 建立的连接
+func (l *InMemoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+// Close 关闭 listener，之后的 Accept 和 Dial 都会返回 ErrListenerClosed，已经建立的连接不受影响
+func (l *InMemoryListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *InMemoryListener) Addr() net.Addr {
+	return inMemoryAddr{}
+}
+
+// Dial 建立一个连接，返回客户端一侧，服务端一侧由 Accept 返回
+func (l *InMemoryListener) Dial() (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		_ = server.Close()
+		_ = client.Close()
+		return nil, ErrListenerClosed
+	}
+}
+
+// DialClient 建立连接并创建 Client
+func (l *InMemoryListener) DialClient(opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := l.Dial()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opt)
+}
+
+// inMemoryAddr InMemoryListener 的地址
+type inMemoryAddr struct{}
+
+func (inMemoryAddr) Network() string { return "memory" }
+func (inMemoryAddr) String() string  { return "memory" }
@@ -0,0 +1,37 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInMemoryListener(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l := NewInMemoryListener()
+	go server.Accept(l)
+
+	for _, opt := range []*Option{nil, {CodecType: "application/json"}} {
+		client, err := l.DialClient(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+		}
+		_ = client.Close()
+	}
+
+	_ = l.Close()
+	if _, err := l.DialClient(); err != ErrListenerClosed {
+		t.Fatalf("expect ErrListenerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// UnaryServerInfo 服务端拦截器可以获取到的请求信息
+type UnaryServerInfo struct {
+	ServiceMethod string // ServiceMethod 格式："{service}.{method}"
+	Seq           uint64 // Seq 请求的编号
+}
+
+// UnaryHandler 执行 RPC 方法，req 为方法的参数，返回方法的返回值
+type UnaryHandler func(ctx context.Context, req any) (reply any, err error)
+
+// UnaryServerInterceptor 服务端拦截器，调用 handler 继续执行，不调用则中断请求。
+// 可以用来实现鉴权、日志、监控、限流等通用逻辑。
+type UnaryServerInterceptor func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (reply any, err error)
+
+// UnaryInvoker 发送请求并等待响应
+type UnaryInvoker func(ctx context.Context, serviceMethod string, args, reply any) error
+
+// UnaryClientInterceptor 客户端拦截器，调用 invoker 发送请求，不调用则中断请求。
+type UnaryClientInterceptor func(ctx context.Context, serviceMethod string, args, reply any, invoker UnaryInvoker) error
+
+// chainUnaryServerInterceptors 将多个拦截器合并为一个，按添加的顺序执行，第一个在最外层
+func chainUnaryServerInterceptors(interceptors []UnaryServerInterceptor) UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		return interceptors[0](ctx, req, info, chainUnaryHandler(interceptors, 0, info, handler))
+	}
+}
+
+// chainUnaryHandler 返回执行第 curr 个之后拦截器的 handler
+func chainUnaryHandler(interceptors []UnaryServerInterceptor, curr int, info *UnaryServerInfo, finalHandler UnaryHandler) UnaryHandler {
+	if curr == len(interceptors)-1 {
+		return finalHandler
+	}
+
+	return func(ctx context.Context, req any) (any, error) {
+		return interceptors[curr+1](ctx, req, info, chainUnaryHandler(interceptors, curr+1, info, finalHandler))
+	}
+}
+
+// chainUnaryClientInterceptors 将多个拦截器合并为一个，按添加的顺序执行，第一个在最外层
+func chainUnaryClientInterceptors(interceptors []UnaryClientInterceptor) UnaryClientInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, serviceMethod string, args, reply any, invoker UnaryInvoker) error {
+		return interceptors[0](ctx, serviceMethod, args, reply, chainUnaryInvoker(interceptors, 0, invoker))
+	}
+}
+
+// chainUnaryInvoker 返回执行第 curr 个之后拦截器的 invoker
+func chainUnaryInvoker(interceptors []UnaryClientInterceptor, curr int, finalInvoker UnaryInvoker) UnaryInvoker {
+	if curr == len(interceptors)-1 {
+		return finalInvoker
+	}
+
+	return func(ctx context.Context, serviceMethod string, args, reply any) error {
+		return interceptors[curr+1](ctx, serviceMethod, args, reply, chainUnaryInvoker(interceptors, curr+1, finalInvoker))
+	}
+}
+
+// Logger 打印请求的方法、耗时和错误的服务端拦截器
+func Logger() UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		t := time.Now()
+		reply, err := handler(ctx, req)
+		log.Printf("rpc server: [%d] %s in %v, err: %v", info.Seq, info.ServiceMethod, time.Since(t), err)
+		return reply, err
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestServer_Use(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	var order []string
+	server.Use(func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		order = append(order, "first")
+		return handler(ctx, req)
+	}, func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		order = append(order, "second")
+		if args, ok := req.(Args); ok && args.Num1 < 0 {
+			return nil, errors.New("permission denied")
+		}
+		return handler(ctx, req)
+	})
+
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, _ := Dial("tcp", l.Addr().String())
+	defer func() { _ = client.Close() }()
+
+	var clientOrder []string
+	client.Use(func(ctx context.Context, serviceMethod string, args, reply any, invoker UnaryInvoker) error {
+		clientOrder = append(clientOrder, "first")
+		return invoker(ctx, serviceMethod, args, reply)
+	}, func(ctx context.Context, serviceMethod string, args, reply any, invoker UnaryInvoker) error {
+		clientOrder = append(clientOrder, "second")
+		return invoker(ctx, serviceMethod, args, reply)
+	})
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Fatalf("server interceptors should run in order, but got %v", order)
+	}
+	if !reflect.DeepEqual(clientOrder, []string{"first", "second"}) {
+		t.Fatalf("client interceptors should run in order, but got %v", clientOrder)
+	}
+
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: -1, Num2: 2}, &reply); err == nil || err.Error() != "permission denied" {
+		t.Fatalf("expect permission denied, but got %v", err)
+	}
+}
+
+func TestClient_UseGoAndNotify(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, _ := Dial("tcp", l.Addr().String())
+	defer func() { _ = client.Close() }()
+
+	seen := make(chan string, 2)
+	client.Use(func(ctx context.Context, serviceMethod string, args, reply any, invoker UnaryInvoker) error {
+		seen <- serviceMethod
+		if args.(*Args).Num1 < 0 {
+			return errors.New("permission denied")
+		}
+		return invoker(ctx, serviceMethod, args, reply)
+	})
+
+	var reply int
+	call := <-client.Go("Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply, nil).Done
+	if call.Error != nil || reply != 3 || <-seen != "Foo.Sum" {
+		t.Fatalf("expect 3 through the interceptor, but got %d, err: %v", reply, call.Error)
+	}
+
+	call = <-client.Go("Foo.Sum", &Args{Num1: -1}, &reply, nil).Done
+	if call.Error == nil || call.Error.Error() != "permission denied" {
+		t.Fatalf("expect permission denied, but got %v", call.Error)
+	}
+	<-seen
+
+	if err := client.Notify(context.Background(), "Foo.Sum", &Args{Num1: -1}); err == nil || err.Error() != "permission denied" {
+		t.Fatalf("expect permission denied, but got %v", err)
+	}
+	if got := <-seen; got != "Foo.Sum" {
+		t.Fatalf("expect Notify through the interceptor, but got %s", got)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrResourceExhausted 请求超过服务端的限制被拒绝，客户端可以通过 errors.Is 判断后稍后重试
+var ErrResourceExhausted = errors.New("rpc server: resource exhausted")
+
+// Limit 限流配置，字段为 0 表示不限制
+type Limit struct {
+	MaxInFlight int     // MaxInFlight 同时处理的请求数上限，包括未结束的流
+	QPS         float64 // QPS 令牌桶每秒生成的令牌数，每个请求消耗一个
+	Burst       int     // Burst 令牌桶的容量，即允许的突发请求数，默认为 QPS 向上取整
+}
+
+// limiter 按 Limit 限制并发数和 QPS，超过限制时直接拒绝，不排队等待
+type limiter struct {
+	name     string // name 用于错误信息，例如：server、connection、Foo.Sum
+	max      int64
+	inFlight int64 // inFlight 原子操作
+
+	mu     sync.Mutex // mu 保护令牌桶
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter 创建 limiter，没有任何限制时返回 nil
+func newLimiter(name string, l Limit) *limiter {
+	if l.MaxInFlight <= 0 && l.QPS <= 0 {
+		return nil
+	}
+
+	lim := &limiter{name: name, max: int64(l.MaxInFlight), rate: l.QPS}
+	if l.QPS > 0 {
+		lim.burst = float64(l.Burst)
+		if l.Burst <= 0 {
+			lim.burst = float64(int(l.QPS + 0.999))
+		}
+		lim.tokens = lim.burst
+		lim.last = time.Now()
+	}
+	return lim
+}
+
+// acquire 占用一个并发数和一个令牌，超过限制时返回错误
+func (lim *limiter) acquire() error {
+	if lim.max > 0 && atomic.AddInt64(&lim.inFlight, 1) > lim.max {
+		atomic.AddInt64(&lim.inFlight, -1)
+		return fmt.Errorf("%w: %s in-flight limit %d", ErrResourceExhausted, lim.name, lim.max)
+	}
+
+	if lim.rate > 0 && !lim.allow() {
+		lim.release()
+		return fmt.Errorf("%w: %s rate limit %g qps", ErrResourceExhausted, lim.name, lim.rate)
+	}
+	return nil
+}
+
+// release 请求处理完成，归还并发数
+func (lim *limiter) release() {
+	if lim.max > 0 {
+		atomic.AddInt64(&lim.inFlight, -1)
+	}
+}
+
+// undo 撤销一次成功的 acquire，归还并发数和令牌，用于之后的限制拒绝了请求时
+func (lim *limiter) undo() {
+	lim.release()
+	if lim.rate <= 0 {
+		return
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	if lim.tokens++; lim.tokens > lim.burst {
+		lim.tokens = lim.burst
+	}
+}
+
+// allow 从令牌桶中取一个令牌
+func (lim *limiter) allow() bool {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now := time.Now()
+	lim.tokens += now.Sub(lim.last).Seconds() * lim.rate
+	if lim.tokens > lim.burst {
+		lim.tokens = lim.burst
+	}
+	lim.last = now
+
+	if lim.tokens < 1 {
+		return false
+	}
+	lim.tokens--
+	return true
+}
+
+// SetLimit 设置整个服务的限制，需要在开始处理请求前调用
+func (server *Server) SetLimit(l Limit) {
+	server.limiter = newLimiter("server", l)
+}
+
+// SetConnLimit 设置每个连接的限制，对之后建立的连接生效
+func (server *Server) SetConnLimit(l Limit) {
+	server.connLimit = l
+}
+
+// SetMethodLimit 设置一个方法的限制，serviceMethod 格式为 "Service.Method"
+func (server *Server) SetMethodLimit(serviceMethod string, l Limit) {
+	if lim := newLimiter(serviceMethod, l); lim != nil {
+		server.methodLimiters.Store(serviceMethod, lim)
+	} else {
+		server.methodLimiters.Delete(serviceMethod)
+	}
+}
+
+// acquire 依次检查服务、连接和方法的限制，全部通过后返回释放的函数。
+// 某个限制拒绝时撤销之前已经通过的限制，被拒绝的请求不消耗服务和连接的 QPS。
+func (server *Server) acquire(conn *limiter, serviceMethod string) (release func(), err error) {
+	lims := make([]*limiter, 0, 3)
+	if server.limiter != nil {
+		lims = append(lims, server.limiter)
+	}
+	if conn != nil {
+		lims = append(lims, conn)
+	}
+	if lim, ok := server.methodLimiters.Load(serviceMethod); ok {
+		lims = append(lims, lim.(*limiter))
+	}
+
+	for i, lim := range lims {
+		if err := lim.acquire(); err != nil {
+			for _, acquired := range lims[:i] {
+				acquired.undo()
+			}
+			return nil, err
+		}
+	}
+
+	return func() {
+		for _, lim := range lims {
+			lim.release()
+		}
+	}, nil
+}
+
+// serverError 将服务端返回的错误信息转换为 error，限流的错误可以通过 errors.Is 判断
+func serverError(msg string) error {
+	if rest := strings.TrimPrefix(msg, ErrResourceExhausted.Error()); rest != msg {
+		return fmt.Errorf("%w%s", ErrResourceExhausted, rest)
+	}
+	return errors.New(msg)
+}
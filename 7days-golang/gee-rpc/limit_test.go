@@ -0,0 +1,174 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	if newLimiter("none", Limit{}) != nil {
+		t.Fatal("expect nil limiter without limits")
+	}
+
+	lim := newLimiter("Foo.Sum", Limit{QPS: 10, Burst: 2})
+	for i := 0; i < 2; i++ {
+		if err := lim.acquire(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lim.acquire(); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted, got %v", err)
+	}
+
+	// 100ms 生成一个令牌
+	time.Sleep(time.Millisecond * 120)
+	if err := lim.acquire(); err != nil {
+		t.Fatal(err)
+	}
+
+	lim = newLimiter("server", Limit{MaxInFlight: 1})
+	if err := lim.acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lim.acquire(); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted, got %v", err)
+	}
+	lim.release()
+	if err := lim.acquire(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_SetLimit(t *testing.T) {
+	server, l := startServer(t)
+	defer func() { _ = l.Close() }()
+	server.SetConnLimit(Limit{MaxInFlight: 1})
+	server.SetMethodLimit("Foo.Sum", Limit{QPS: 1, Burst: 1})
+
+	dial := func() *Client {
+		client, err := Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+	c1, c2 := dial(), dial()
+	ctx := context.Background()
+
+	// c1 上有一个请求在处理，同一个连接上的请求被拒绝，其他连接不受影响
+	done := make(chan error, 1)
+	go func() {
+		done <- c1.Call(ctx, "Foo.Sleep", &Args{Num1: 1}, new(int))
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	if err := c1.Call(ctx, "Foo.Sum", &Args{}, new(int)); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted for connection limit, got %v", err)
+	}
+	if err := c2.Call(ctx, "Foo.Sum", &Args{Num1: 1}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Foo.Sum 每秒只允许一次
+	if err := c2.Call(ctx, "Foo.Sum", &Args{}, new(int)); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted for method limit, got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_SetLimitRefund(t *testing.T) {
+	server, l := startServer(t)
+	defer func() { _ = l.Close() }()
+	server.SetLimit(Limit{QPS: 0.1, Burst: 2})
+	server.SetMethodLimit("Foo.Sum", Limit{QPS: 0.1, Burst: 1})
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	if err := client.Call(ctx, "Foo.Sum", &Args{}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.Call(ctx, "Foo.Sum", &Args{}, new(int)); !errors.Is(err, ErrResourceExhausted) {
+			t.Fatalf("expect ErrResourceExhausted for method limit, got %v", err)
+		}
+	}
+
+	// 被方法限制拒绝的请求归还了服务的令牌，其他方法不受影响
+	if err := client.Call(ctx, "Foo.Sleep", &Args{}, new(int)); err != nil {
+		t.Fatalf("expect the server budget to be available, got %v", err)
+	}
+}
+
+func TestServer_SetLimitStream(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(new(Log))
+	server.SetLimit(Limit{MaxInFlight: 1})
+
+	lis, _ := net.Listen("tcp", ":0")
+	defer func() { _ = lis.Close() }()
+	go server.Accept(lis)
+
+	client, err := Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	stream, err := client.NewStream(context.Background(), "Log.Chat", "", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	// 未结束的流占用并发数，超过限制的流打开后立即以错误结束
+	rejected, err := client.NewStream(context.Background(), "Log.Chat", "", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rejected.Recv(new(string)); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted, got %v", err)
+	}
+}
+
+func TestServer_SetLimitHandleTimeout(t *testing.T) {
+	server, l := startServer(t)
+	defer func() { _ = l.Close() }()
+	server.SetLimit(Limit{MaxInFlight: 1})
+
+	client, err := Dial("tcp", l.Addr().String(), &Option{HandleTimeout: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	if err := client.Call(ctx, "Foo.Sleep", &Args{Num1: 1}, new(int)); err == nil {
+		t.Fatal("expect a handle timeout error")
+	}
+
+	// 超时后 Foo.Sleep 仍在执行，继续占用并发数
+	if err := client.Call(ctx, "Foo.Sum", &Args{}, new(int)); !errors.Is(err, ErrResourceExhausted) {
+		t.Fatalf("expect ErrResourceExhausted while the timed-out call is running, got %v", err)
+	}
+
+	time.Sleep(time.Second)
+	if err := client.Call(ctx, "Foo.Sum", &Args{}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+}
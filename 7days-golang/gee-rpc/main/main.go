@@ -5,7 +5,7 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"geerpc"
 	"log"
 	"net"
@@ -13,8 +13,26 @@ import (
 	"time"
 )
 
+// Foo 用于演示的服务
+type Foo int
+
+// Args Foo.Sum 的参数
+type Args struct{ Num1, Num2 int }
+
+// Sum 求和，满足 func (t *T) MethodName(argv T1, replyv *T2) error 的形式
+func (f Foo) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
 // startServer 开启服务端服务
 func startServer(addr chan string) {
+	var foo Foo
+	if err := geerpc.Register(&foo); err != nil {
+		log.Fatal("register error:", err)
+	}
+	geerpc.DefaultServer.Use(geerpc.Logger())
+
 	l, err := net.Listen("tcp", ":0")
 
 	if err != nil {
@@ -35,6 +53,13 @@ func main() {
 		_ = client.Close()
 	}()
 
+	// 客户端拦截器，打印每次调用的结果
+	client.Use(func(ctx context.Context, serviceMethod string, args, reply any, invoker geerpc.UnaryInvoker) error {
+		err := invoker(ctx, serviceMethod, args, reply)
+		log.Println("rpc client:", serviceMethod, args, err)
+		return err
+	})
+
 	time.Sleep(1 * time.Second)
 	// 发送请求并接收请求
 	var wg sync.WaitGroup
@@ -43,35 +68,14 @@ func main() {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			args := fmt.Sprintf("geerpc req %d", i)
-			var reply string
-			if err := client.Call("Foo.Sum", args, &reply); err != nil {
+			args := &Args{Num1: i, Num2: i * i}
+			var reply int
+			if err := client.Call(context.Background(), "Foo.Sum", args, &reply); err != nil {
 				log.Fatal("call Foo.Sum err: ", err)
 			}
-			log.Println("返回的 rep 的内容：", reply)
+			log.Printf("%d + %d = %d", args.Num1, args.Num2, reply)
 		}(i)
 	}
 
 	wg.Wait()
 }
-
-// 运行结果：
-/**
-GOROOT=/usr/local/opt/go/libexec #gosetup
-GOPATH=/Users/cathay/go #gosetup
-/usr/local/opt/go/libexec/bin/go build -o /private/var/folders/9s/b1bl7w_x0vv9w_pbscjgcflm0000gn/T/GoLand/___go_build_geerpc . #gosetup
-/private/var/folders/9s/b1bl7w_x0vv9w_pbscjgcflm0000gn/T/GoLand/___go_build_geerpc
-start rpc server on [::]:55971
-请求的 req 头和内容： &{Foo.Sum 0 } geerpc req 0
-返回的 rep 头和内容： &{Foo.Sum 0 } geerpc rep 0
-请求的 req 头和内容： &{Foo.Sum 1 } geerpc req 1
-返回的 rep 头和内容： &{Foo.Sum 1 } geerpc rep 1
-请求的 req 头和内容： &{Foo.Sum 2 } geerpc req 2
-返回的 rep 头和内容： &{Foo.Sum 2 } geerpc rep 2
-请求的 req 头和内容： &{Foo.Sum 3 } geerpc req 3
-返回的 rep 头和内容： &{Foo.Sum 3 } geerpc rep 3
-请求的 req 头和内容： &{Foo.Sum 4 } geerpc req 4
-返回的 rep 头和内容： &{Foo.Sum 4 } geerpc rep 4
-
-Process finished with the exit code 0
-*/
@@ -0,0 +1,84 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Metadata 随请求一起发送的元数据，例如：trace id、鉴权 token、租户 ID
+type Metadata map[string]string
+
+// timeoutKey 保存请求剩余超时时间（纳秒）的保留 key，服务端据此继承调用方的 deadline
+const timeoutKey = "geerpc-timeout"
+
+type (
+	outgoingKey struct{}
+	incomingKey struct{}
+)
+
+// Copy 返回一份拷贝
+func (md Metadata) Copy() Metadata {
+	out := make(Metadata, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// NewOutgoingContext 返回携带 md 的 context，客户端使用它发起调用时 md 会随请求发送
+func NewOutgoingContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, outgoingKey{}, md)
+}
+
+// FromOutgoingContext 返回 ctx 中将要发送的元数据
+func FromOutgoingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(outgoingKey{}).(Metadata)
+	return md, ok
+}
+
+// newIncomingContext 返回携带收到的元数据的 context，服务端使用
+func newIncomingContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, incomingKey{}, md)
+}
+
+// FromIncomingContext 返回服务端收到的元数据，在拦截器和带 context.Context 参数的方法中使用
+func FromIncomingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(incomingKey{}).(Metadata)
+	return md, ok
+}
+
+// outgoingMetadata 返回需要随请求发送的元数据，包括 ctx 的剩余超时时间
+func outgoingMetadata(ctx context.Context) Metadata {
+	md, _ := FromOutgoingContext(ctx)
+	deadline, ok := ctx.Deadline()
+	if len(md) == 0 && !ok {
+		return nil
+	}
+
+	md = md.Copy()
+	if ok {
+		md[timeoutKey] = strconv.FormatInt(int64(time.Until(deadline)), 10)
+	}
+	return md
+}
+
+// parseIncomingMetadata 从收到的元数据中取出剩余超时时间，返回的 md 不包含保留 key
+func parseIncomingMetadata(header map[string]string) (md Metadata, timeout time.Duration) {
+	md = Metadata(header).Copy()
+	if v, ok := md[timeoutKey]; ok {
+		delete(md, timeoutKey)
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			timeout = time.Duration(n)
+			// 已经超时的请求至少给 1 纳秒，保证 ctx 会被取消
+			if timeout <= 0 {
+				timeout = 1
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+type Bar int
+
+type MetaReply struct {
+	TraceID     string
+	HasDeadline bool
+	Remaining   time.Duration
+}
+
+func (b Bar) Meta(ctx context.Context, args Args, reply *MetaReply) error {
+	md, _ := FromIncomingContext(ctx)
+	reply.TraceID = md["trace-id"]
+	if deadline, ok := ctx.Deadline(); ok {
+		reply.HasDeadline = true
+		reply.Remaining = time.Until(deadline)
+	}
+	return nil
+}
+
+func TestMetadata(t *testing.T) {
+	server := NewServer()
+	var bar Bar
+	_ = server.Register(&bar)
+
+	var interceptorTraceID string
+	server.Use(func(ctx context.Context, req any, info *UnaryServerInfo, handler UnaryHandler) (any, error) {
+		md, _ := FromIncomingContext(ctx)
+		interceptorTraceID = md["trace-id"]
+		return handler(ctx, req)
+	})
+
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	for _, opt := range []*Option{DefaultOption, {CodecType: "application/json"}} {
+		client, err := Dial("tcp", l.Addr().String(), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		md := Metadata{"trace-id": "abc"}
+		ctx := NewOutgoingContext(context.Background(), md)
+		var reply MetaReply
+		if err := client.Call(ctx, "Bar.Meta", &Args{}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.TraceID != "abc" || interceptorTraceID != "abc" || reply.HasDeadline {
+			t.Fatalf("%s: metadata should be propagated without deadline, but got %+v", opt.CodecType, reply)
+		}
+
+		// 剩余超时时间随请求传递，服务端的 ctx 继承 deadline
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		if err := client.Call(ctx, "Bar.Meta", &Args{}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if !reply.HasDeadline || reply.Remaining <= 0 || reply.Remaining > time.Second*5 {
+			t.Fatalf("%s: deadline should be propagated, but got %+v", opt.CodecType, reply)
+		}
+
+		if _, ok := md[timeoutKey]; ok {
+			t.Fatal("outgoing metadata of the caller should not be modified")
+		}
+		_ = client.Close()
+	}
+}
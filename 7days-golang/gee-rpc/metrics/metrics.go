@@ -0,0 +1,210 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package metrics 统计 geerpc 服务端和客户端每个方法的调用次数、错误次数、正在处理的请求数和耗时分布，
+// 以 Prometheus 文本格式输出。
+//
+// 通过拦截器接入：
+//
+//	reg := metrics.NewRegistry()
+//	server.Use(reg.ServerInterceptor())
+//	client.Use(reg.ClientInterceptor())
+//	http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"geerpc"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets 默认的耗时分桶上限，单位为秒
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry 保存所有方法的统计数据，实现了 http.Handler
+type Registry struct {
+	buckets []float64 // buckets 耗时分桶的上限，递增
+	server  *side     // server 服务端的统计
+	client  *side     // client 客户端的统计
+}
+
+var _ http.Handler = (*Registry)(nil)
+
+// NewRegistry 创建 Registry，buckets 为空时使用 DefaultBuckets
+func NewRegistry(buckets ...float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Registry{
+		buckets: buckets,
+		server:  &side{name: "server", methods: make(map[string]*methodMetrics)},
+		client:  &side{name: "client", methods: make(map[string]*methodMetrics)},
+	}
+}
+
+// side 服务端或客户端一侧的统计，key 为 ServiceMethod
+type side struct {
+	name    string
+	mu      sync.RWMutex
+	methods map[string]*methodMetrics
+}
+
+// methodMetrics 一个方法的统计，字段都是原子操作
+type methodMetrics struct {
+	calls    uint64   // calls 完成的调用次数
+	errors   uint64   // errors 返回错误的次数
+	inFlight int64    // inFlight 正在处理的请求数
+	sumNanos uint64   // sumNanos 耗时总和，单位为纳秒
+	buckets  []uint64 // buckets 每个分桶的计数，不累加，最后一个为 +Inf
+}
+
+// get 返回方法的统计，不存在时创建
+func (s *side) get(serviceMethod string, buckets int) *methodMetrics {
+	s.mu.RLock()
+	m := s.methods[serviceMethod]
+	s.mu.RUnlock()
+	if m != nil {
+		return m
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m = s.methods[serviceMethod]; m == nil {
+		m = &methodMetrics{buckets: make([]uint64, buckets+1)}
+		s.methods[serviceMethod] = m
+	}
+	return m
+}
+
+// observe 记录一次调用的开始，返回的函数在调用结束时执行
+func (r *Registry) observe(s *side, serviceMethod string) func(err error) {
+	m := s.get(serviceMethod, len(r.buckets))
+	atomic.AddInt64(&m.inFlight, 1)
+	start := time.Now()
+
+	return func(err error) {
+		d := time.Since(start)
+		atomic.AddInt64(&m.inFlight, -1)
+		atomic.AddUint64(&m.calls, 1)
+		if err != nil {
+			atomic.AddUint64(&m.errors, 1)
+		}
+		atomic.AddUint64(&m.sumNanos, uint64(d))
+		i := sort.SearchFloat64s(r.buckets, d.Seconds())
+		atomic.AddUint64(&m.buckets[i], 1)
+	}
+}
+
+// ServerInterceptor 统计服务端方法的拦截器。
+// 在找到方法并解码参数之后执行，找不到方法等错误不会被统计；方法 panic 和处理超时记为失败。
+func (r *Registry) ServerInterceptor() geerpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *geerpc.UnaryServerInfo, handler geerpc.UnaryHandler) (reply any, err error) {
+		done := r.observe(r.server, info.ServiceMethod)
+		defer finish(done, &err)
+		return handler(ctx, req)
+	}
+}
+
+// ClientInterceptor 统计客户端调用的拦截器，包括超时和连接错误
+func (r *Registry) ClientInterceptor() geerpc.UnaryClientInterceptor {
+	return func(ctx context.Context, serviceMethod string, args, reply any, invoker geerpc.UnaryInvoker) (err error) {
+		done := r.observe(r.client, serviceMethod)
+		defer finish(done, &err)
+		return invoker(ctx, serviceMethod, args, reply)
+	}
+}
+
+// finish 在 defer 中结束一次调用的统计，panic 时记为失败后继续 panic
+func finish(done func(err error), err *error) {
+	if p := recover(); p != nil {
+		done(fmt.Errorf("panic: %v", p))
+		panic(p)
+	}
+	done(*err)
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出所有统计
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+// WriteText 以 Prometheus 文本格式写入所有统计，方法按名称排序
+func (r *Registry) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, s := range []*side{r.server, r.client} {
+		r.writeSide(&b, s)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSide 写入一侧的统计
+func (r *Registry) writeSide(b *strings.Builder, s *side) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	methods := make([]*methodMetrics, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		methods[i] = s.methods[name]
+	}
+	s.mu.RUnlock()
+
+	prefix := "geerpc_" + s.name + "_"
+	header := func(name, typ, help string) {
+		fmt.Fprintf(b, "# HELP %s%s %s\n# TYPE %s%s %s\n", prefix, name, help, prefix, name, typ)
+	}
+
+	header("calls_total", "counter", "Total number of completed RPC calls.")
+	for i, m := range methods {
+		fmt.Fprintf(b, "%scalls_total{method=%s} %d\n", prefix, quote(names[i]), atomic.LoadUint64(&m.calls))
+	}
+
+	header("errors_total", "counter", "Total number of RPC calls that returned an error.")
+	for i, m := range methods {
+		fmt.Fprintf(b, "%serrors_total{method=%s} %d\n", prefix, quote(names[i]), atomic.LoadUint64(&m.errors))
+	}
+
+	header("in_flight", "gauge", "Number of RPC calls in progress.")
+	for i, m := range methods {
+		fmt.Fprintf(b, "%sin_flight{method=%s} %d\n", prefix, quote(names[i]), atomic.LoadInt64(&m.inFlight))
+	}
+
+	header("latency_seconds", "histogram", "Latency of completed RPC calls in seconds.")
+	for i, m := range methods {
+		method := quote(names[i])
+		var cumulative uint64
+		for j := range m.buckets {
+			cumulative += atomic.LoadUint64(&m.buckets[j])
+			le := "+Inf"
+			if j < len(r.buckets) {
+				le = strconv.FormatFloat(r.buckets[j], 'g', -1, 64)
+			}
+			fmt.Fprintf(b, "%slatency_seconds_bucket{method=%s,le=\"%s\"} %d\n", prefix, method, le, cumulative)
+		}
+		sum := time.Duration(atomic.LoadUint64(&m.sumNanos)).Seconds()
+		fmt.Fprintf(b, "%slatency_seconds_sum{method=%s} %s\n", prefix, method, strconv.FormatFloat(sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%slatency_seconds_count{method=%s} %d\n", prefix, method, cumulative)
+	}
+}
+
+// quote 按 Prometheus 的规则转义标签值
+func quote(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
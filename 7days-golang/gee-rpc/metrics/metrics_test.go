@@ -0,0 +1,178 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Foo int
+
+type Args struct{ Num1, Num2 int }
+
+func (f Foo) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func (f Foo) Fail(args Args, reply *int) error {
+	return errors.New("failed")
+}
+
+func (f Foo) Boom(args Args, reply *int) error {
+	panic("boom")
+}
+
+func (f Foo) Slow(args Args, reply *int) error {
+	time.Sleep(time.Millisecond * 200)
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(0.5, 1)
+
+	server := geerpc.NewServer()
+	server.Use(reg.ServerInterceptor())
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, err := geerpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	client.Use(reg.ClientInterceptor())
+
+	var reply int
+	ctx := context.Background()
+	_ = client.Call(ctx, "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_ = client.Call(ctx, "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_ = client.Call(ctx, "Foo.Fail", Args{}, &reply)
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	out := string(body)
+
+	for _, want := range []string{
+		"# TYPE geerpc_server_calls_total counter",
+		`geerpc_server_calls_total{method="Foo.Sum"} 2`,
+		`geerpc_server_errors_total{method="Foo.Fail"} 1`,
+		`geerpc_server_errors_total{method="Foo.Sum"} 0`,
+		`geerpc_server_in_flight{method="Foo.Sum"} 0`,
+		"# TYPE geerpc_server_latency_seconds histogram",
+		`geerpc_server_latency_seconds_bucket{method="Foo.Sum",le="0.5"} 2`,
+		`geerpc_server_latency_seconds_bucket{method="Foo.Sum",le="+Inf"} 2`,
+		`geerpc_server_latency_seconds_count{method="Foo.Sum"} 2`,
+		`geerpc_client_calls_total{method="Foo.Fail"} 1`,
+		`geerpc_client_errors_total{method="Foo.Fail"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expect %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestRegistry_inFlight(t *testing.T) {
+	reg := NewRegistry()
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req any) (any, error) {
+		<-release
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = reg.ServerInterceptor()(context.Background(), nil, &geerpc.UnaryServerInfo{ServiceMethod: "Foo.Slow"}, handler)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(text(reg), `geerpc_server_in_flight{method="Foo.Slow"} 1`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect 1 in-flight call:\n%s", text(reg))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	<-done
+	if out := text(reg); !strings.Contains(out, `geerpc_server_in_flight{method="Foo.Slow"} 0`) {
+		t.Fatalf("expect 0 in-flight call:\n%s", out)
+	}
+}
+
+func TestRegistry_panicAndTimeout(t *testing.T) {
+	reg := NewRegistry()
+
+	server := geerpc.NewServer()
+	server.Use(reg.ServerInterceptor())
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, err := geerpc.Dial("tcp", l.Addr().String(), &geerpc.Option{HandleTimeout: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	if err := client.Call(ctx, "Foo.Boom", Args{}, new(int)); err == nil {
+		t.Fatal("expect a panic error")
+	}
+	if err := client.Call(ctx, "Foo.Slow", Args{}, new(int)); err == nil || !strings.Contains(err.Error(), "handle timeout") {
+		t.Fatalf("expect handle timeout, but got %v", err)
+	}
+
+	// 超时后方法仍在执行，执行完成后才记录
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(text(reg), `geerpc_server_calls_total{method="Foo.Slow"} 1`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect Foo.Slow to be recorded:\n%s", text(reg))
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	out := text(reg)
+	for _, want := range []string{
+		`geerpc_server_calls_total{method="Foo.Boom"} 1`,
+		`geerpc_server_errors_total{method="Foo.Boom"} 1`,
+		`geerpc_server_in_flight{method="Foo.Boom"} 0`,
+		`geerpc_server_errors_total{method="Foo.Slow"} 1`,
+		`geerpc_server_in_flight{method="Foo.Slow"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expect %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func text(reg *Registry) string {
+	var b strings.Builder
+	_ = reg.WriteText(&b)
+	return b.String()
+}
+
+func TestQuote(t *testing.T) {
+	if got := quote("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Fatalf("unexpected %s", got)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// tlsHandshakeTimeout 服务端等待 TLS 握手完成的时间，避免不发送 ClientHello 的连接一直占用协程
+var tlsHandshakeTimeout = time.Second * 10
+
+// Peer 请求的对端，即发起调用的客户端
+type Peer struct {
+	Addr net.Addr             // Addr 对端地址，未知时为 nil
+	TLS  *tls.ConnectionState // TLS 握手完成后的连接状态，非 TLS 连接为 nil
+}
+
+type peerKey struct{}
+
+// newPeerContext 返回携带对端信息的 context，服务端使用
+func newPeerContext(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+// PeerFromContext 返回服务端 ctx 中的对端信息，在拦截器和方法中用于鉴权
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+// CommonName 返回双向 TLS 中已验证的客户端证书的 CommonName，
+// 客户端没有提供证书或者证书没有经过验证时返回空字符串
+func (p *Peer) CommonName() string {
+	if p.TLS == nil || len(p.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	return p.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// newPeer 根据连接创建 Peer，TLS 连接需要先在 tlsHandshakeTimeout 内完成握手
+func newPeer(conn any) (*Peer, error) {
+	p := new(Peer)
+	if c, ok := conn.(net.Conn); ok {
+		p.Addr = c.RemoteAddr()
+	}
+
+	if c, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+		defer cancel()
+		if err := c.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		state := c.ConnectionState()
+		p.TLS = &state
+	}
+	return p, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type Whoami struct{}
+
+// Name 返回调用方的身份，非双向 TLS 时返回对端地址的类型
+func (w Whoami) Name(ctx context.Context, _ int, reply *string) error {
+	p, ok := PeerFromContext(ctx)
+	if !ok {
+		*reply = "unknown"
+		return nil
+	}
+
+	*reply = p.CommonName()
+	if *reply == "" {
+		*reply = p.Addr.Network()
+		if p.TLS != nil {
+			*reply = "tls"
+		}
+	}
+	return nil
+}
+
+// testCA 测试用的自签名 CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "geerpc test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发 CommonName 为 cn 的证书，同时可以用于服务端和客户端
+func (ca *testCA) issue(t *testing.T, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTLSServer 启动 TLS 服务，返回监听地址
+func startTLSServer(t *testing.T, config *tls.Config) string {
+	server := NewServer()
+	_ = server.Register(Whoami{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.AcceptTLS(l, config)
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String()
+}
+
+func callWhoami(t *testing.T, addr string, opt *Option) (string, error) {
+	client, err := Dial("tcp", addr, opt)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply string
+	err = client.Call(context.Background(), "Whoami.Name", 0, &reply)
+	return reply, err
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, "server")}})
+
+	reply, err := callWhoami(t, addr, &Option{TLSConfig: &tls.Config{RootCAs: ca.pool}})
+	if err != nil || reply != "tls" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+
+	// 不信任服务端证书时握手失败
+	if _, err := callWhoami(t, addr, &Option{TLSConfig: &tls.Config{}}); err == nil {
+		t.Fatal("expect error for unknown certificate authority")
+	}
+
+	// 明文客户端无法通过握手
+	if _, err := callWhoami(t, addr, &Option{ConnectTimeout: time.Second}); err == nil {
+		t.Fatal("expect error for plaintext client")
+	}
+}
+
+func TestTLS_handshakeTimeout(t *testing.T) {
+	defer func(d time.Duration) { tlsHandshakeTimeout = d }(tlsHandshakeTimeout)
+	tlsHandshakeTimeout = time.Millisecond * 100
+
+	ca := newTestCA(t)
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, "server")}})
+
+	// 建立连接后不发送 ClientHello，服务端超时后关闭连接
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect the server to close the connection, got %v", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	})
+
+	opt := &Option{TLSConfig: &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{ca.issue(t, "alice")}}}
+	reply, err := callWhoami(t, addr, opt)
+	if err != nil || reply != "alice" {
+		t.Fatalf("expect peer alice, got %q, err = %v", reply, err)
+	}
+
+	// 没有客户端证书时被服务端拒绝
+	if _, err := callWhoami(t, addr, &Option{TLSConfig: &tls.Config{RootCAs: ca.pool}}); err == nil {
+		t.Fatal("expect error without client certificate")
+	}
+}
+
+func TestPeer_plaintext(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(Whoami{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	reply, err := callWhoami(t, l.Addr().String(), nil)
+	if err != nil || reply != "tcp" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoAvailableConn = errors.New("rpc pool: no available connection")
+
+// PoolOption 连接池的配置
+type PoolOption struct {
+	Size       int           // Size 连接数，默认为 4
+	MinBackoff time.Duration // MinBackoff 第一次重连前的等待时间，默认为 100ms
+	MaxBackoff time.Duration // MaxBackoff 重连等待时间的上限，默认为 10s
+}
+
+var DefaultPoolOption = &PoolOption{
+	Size:       4,
+	MinBackoff: time.Millisecond * 100,
+	MaxBackoff: time.Second * 10,
+}
+
+// PoolStats 连接池的统计信息
+type PoolStats struct {
+	Size         int    // Size 连接数
+	Healthy      int    // Healthy 可用的连接数
+	Calls        uint64 // Calls 通过 Pool.Call 发起的调用次数
+	CallErrors   uint64 // CallErrors 调用出错的次数
+	Reconnects   uint64 // Reconnects 重连成功的次数
+	DialFailures uint64 // DialFailures 建立连接失败的次数
+}
+
+// Pool 到同一个地址的连接池。
+// 连接断开后按指数退避自动重连，调用轮流分配到可用的连接上。
+type Pool struct {
+	rpcAddr string      // rpcAddr 格式同 XDial，例如：tcp@127.0.0.1:9999
+	opt     *Option     // opt 创建 Client 使用的 Option
+	popt    *PoolOption // popt 连接池的配置
+
+	mu      sync.Mutex
+	clients []*Client // clients 每个位置的连接，重连期间为 nil
+	closed  bool
+
+	done chan struct{} // done Close 时关闭，结束重连
+	next uint64        // next 轮询的位置，原子操作
+
+	calls        uint64
+	callErrors   uint64
+	reconnects   uint64
+	dialFailures uint64
+}
+
+var _ io.Closer = (*Pool)(nil)
+
+// NewPool 创建连接池，先同步建立所有连接，失败的连接在后台重连。
+// popt 为 nil 时使用 DefaultPoolOption。
+func NewPool(rpcAddr string, popt *PoolOption, opts ...*Option) (*Pool, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	popt = parsePoolOption(popt)
+	p := &Pool{
+		rpcAddr: rpcAddr,
+		opt:     opt,
+		popt:    popt,
+		clients: make([]*Client, popt.Size),
+		done:    make(chan struct{}),
+	}
+
+	for i := range p.clients {
+		client, err := XDial(rpcAddr, opt)
+		if err != nil {
+			atomic.AddUint64(&p.dialFailures, 1)
+		}
+		p.clients[i] = client
+		go p.keepAlive(i, client)
+	}
+	return p, nil
+}
+
+// parsePoolOption 为没有设置的配置赋默认值
+func parsePoolOption(popt *PoolOption) *PoolOption {
+	if popt == nil {
+		return DefaultPoolOption
+	}
+
+	o := *popt
+	if o.Size <= 0 {
+		o.Size = DefaultPoolOption.Size
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultPoolOption.MinBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = DefaultPoolOption.MaxBackoff
+	}
+	return &o
+}
+
+// backoff 第 attempt 次重连前的等待时间，每次翻倍，不超过 MaxBackoff
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.popt.MinBackoff
+	for i := 0; i < attempt && d < p.popt.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.popt.MaxBackoff {
+		d = p.popt.MaxBackoff
+	}
+	return d
+}
+
+// keepAlive 等待第 i 个连接断开后重连，直到连接池关闭
+func (p *Pool) keepAlive(i int, client *Client) {
+	for {
+		if client != nil {
+			select {
+			case <-client.done:
+			case <-p.done:
+				return
+			}
+		}
+
+		p.setClient(i, nil)
+		client = nil
+		for attempt := 0; client == nil; attempt++ {
+			select {
+			case <-time.After(p.backoff(attempt)):
+			case <-p.done:
+				return
+			}
+
+			c, err := XDial(p.rpcAddr, p.opt)
+			if err != nil {
+				atomic.AddUint64(&p.dialFailures, 1)
+				continue
+			}
+
+			if !p.setClient(i, c) {
+				_ = c.Close()
+				return
+			}
+			atomic.AddUint64(&p.reconnects, 1)
+			client = c
+		}
+	}
+}
+
+// setClient 设置第 i 个连接，连接池已经关闭时返回 false
+func (p *Pool) setClient(i int, client *Client) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	p.clients[i] = client
+	return true
+}
+
+// Get 轮询返回一个可用的连接，可以用来调用 Go、NewStream 等方法
+func (p *Pool) Get() (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrShutdown
+	}
+
+	n := uint64(len(p.clients))
+	start := atomic.AddUint64(&p.next, 1)
+	for i := uint64(0); i < n; i++ {
+		client := p.clients[(start+i)%n]
+		if client != nil && client.IsAvailable() {
+			return client, nil
+		}
+	}
+	return nil, ErrNoAvailableConn
+}
+
+// Call 在一个可用的连接上调用方法
+func (p *Pool) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+	atomic.AddUint64(&p.calls, 1)
+
+	client, err := p.Get()
+	if err == nil {
+		err = client.Call(ctx, serviceMethod, args, reply)
+	}
+
+	if err != nil {
+		atomic.AddUint64(&p.callErrors, 1)
+	}
+	return err
+}
+
+// Stats 返回连接池的统计信息
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	healthy := 0
+	for _, client := range p.clients {
+		if client != nil && client.IsAvailable() {
+			healthy++
+		}
+	}
+	size := len(p.clients)
+	p.mu.Unlock()
+
+	return PoolStats{
+		Size:         size,
+		Healthy:      healthy,
+		Calls:        atomic.LoadUint64(&p.calls),
+		CallErrors:   atomic.LoadUint64(&p.callErrors),
+		Reconnects:   atomic.LoadUint64(&p.reconnects),
+		DialFailures: atomic.LoadUint64(&p.dialFailures),
+	}
+}
+
+// Close 关闭所有连接并停止重连
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrShutdown
+	}
+	p.closed = true
+	close(p.done)
+
+	for i, client := range p.clients {
+		if client != nil {
+			_ = client.Close()
+		}
+		p.clients[i] = nil
+	}
+	return nil
+}
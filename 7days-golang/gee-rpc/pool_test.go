@@ -0,0 +1,113 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPool_backoff(t *testing.T) {
+	p := &Pool{popt: &PoolOption{MinBackoff: time.Millisecond * 100, MaxBackoff: time.Second}}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if d := p.backoff(i); d != w*time.Millisecond {
+			t.Fatalf("backoff(%d) = %s, expect %s", i, d, w*time.Millisecond)
+		}
+	}
+}
+
+func TestPool_Call(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	p, err := NewPool("tcp@"+l.Addr().String(), &PoolOption{Size: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Close() }()
+
+	// 调用轮流分配到每个连接
+	seen := make(map[*Client]bool)
+	for i := 0; i < 3; i++ {
+		client, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[client] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expect calls spread across 3 connections, got %d", len(seen))
+	}
+
+	for i := 0; i < 5; i++ {
+		var reply int
+		if err := p.Call(context.Background(), "Foo.Sum", &Args{Num1: i, Num2: 1}, &reply); err != nil || reply != i+1 {
+			t.Fatalf("reply = %d, err = %v", reply, err)
+		}
+	}
+	_ = p.Call(context.Background(), "Foo.Unknown", &Args{}, new(int))
+
+	stats := p.Stats()
+	if stats.Size != 3 || stats.Healthy != 3 || stats.Calls != 6 || stats.CallErrors != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	_ = p.Close()
+	if _, err := p.Get(); err != ErrShutdown {
+		t.Fatalf("expect ErrShutdown after Close, got %v", err)
+	}
+}
+
+func TestPool_reconnect(t *testing.T) {
+	server, l := startServer(t)
+	addr := l.Addr().String()
+
+	p, err := NewPool("tcp@"+addr, &PoolOption{Size: 2, MinBackoff: time.Millisecond * 10, MaxBackoff: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Close() }()
+
+	// 服务端关闭后连接都不可用
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitPool(t, p, func(s PoolStats) bool { return s.Healthy == 0 && s.DialFailures > 0 })
+	if _, err := p.Get(); err != ErrNoAvailableConn {
+		t.Fatalf("expect ErrNoAvailableConn, got %v", err)
+	}
+
+	// 服务端在同一个地址重新启动后自动重连
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("address is not reusable:", err)
+	}
+	server = NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	go server.Accept(l)
+	defer func() { _ = l.Close() }()
+
+	waitPool(t, p, func(s PoolStats) bool { return s.Healthy == 2 && s.Reconnects == 2 })
+	var reply int
+	if err := p.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("reply = %d, err = %v", reply, err)
+	}
+}
+
+// waitPool 等待连接池的统计信息满足条件
+func waitPool(t *testing.T, p *Pool, cond func(PoolStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond(p.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for pool, stats %+v", p.Stats())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
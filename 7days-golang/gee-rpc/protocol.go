@@ -0,0 +1,143 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"geerpc/codec"
+	"io"
+	"time"
+)
+
+// ProtocolVersion 当前的协议版本，握手时发送，服务端拒绝不支持的版本
+const ProtocolVersion = 1
+
+// PreambleSize 握手时客户端发送的固定长度的前导：
+//
+//	| MagicNumber uint32 | version uint8 | codec uint8 | compress uint8 | reserved uint8 | HandleTimeout int64 (ns) |
+//
+// 之后的 header 和 body 都是长度前缀的帧，见 codec.frameCodec。
+const PreambleSize = 16
+
+// 服务端对前导的回复，2 个字节：| version uint8 | status uint8 |
+const (
+	handshakeOK                 byte = iota // handshakeOK 握手成功
+	handshakeUnsupportedVersion             // handshakeUnsupportedVersion 不支持的协议版本
+	handshakeUnknownCodec                   // handshakeUnknownCodec 不支持的编码类型
+	handshakeUnknownCompress                // handshakeUnknownCompress 不支持的压缩算法
+)
+
+var (
+	ErrInvalidMagicNumber  = errors.New("rpc: invalid magic number")
+	ErrUnsupportedVersion  = errors.New("rpc: unsupported protocol version")
+	ErrUnknownCodec        = errors.New("rpc: unknown codec type")
+	ErrUnknownCompress     = errors.New("rpc: unknown compress type")
+	errUnexpectedHandshake = errors.New("rpc: unexpected handshake status")
+)
+
+// writePreamble 客户端发送前导
+func writePreamble(w io.Writer, version byte, opt *Option) error {
+	id, ok := codec.TypeIDs[opt.CodecType]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownCodec, opt.CodecType)
+	}
+	compress, ok := codec.CompressIDs[opt.Compress]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownCompress, opt.Compress)
+	}
+
+	var p [PreambleSize]byte
+	binary.BigEndian.PutUint32(p[0:4], uint32(opt.MagicNumber))
+	p[4] = version
+	p[5] = id
+	p[6] = compress
+	binary.BigEndian.PutUint64(p[8:16], uint64(opt.HandleTimeout))
+	_, err := w.Write(p[:])
+	return err
+}
+
+// readPreamble 服务端读取前导，返回客户端的协议版本和 Option。
+// 编码类型不支持时 Option.CodecType 为空，压缩算法不支持时 ok 为 false，由调用方回复客户端。
+func readPreamble(r io.Reader) (version byte, opt *Option, ok bool, err error) {
+	var p [PreambleSize]byte
+	if _, err = io.ReadFull(r, p[:]); err != nil {
+		return 0, nil, false, err
+	}
+
+	opt = &Option{MagicNumber: int(binary.BigEndian.Uint32(p[0:4]))}
+	if opt.MagicNumber != MagicNumber {
+		return 0, nil, false, fmt.Errorf("%w %x", ErrInvalidMagicNumber, opt.MagicNumber)
+	}
+
+	version = p[4]
+	opt.CodecType, _ = codec.TypeByID(p[5])
+	opt.Compress, ok = codec.CompressTypeByID(p[6])
+	opt.HandleTimeout = time.Duration(binary.BigEndian.Uint64(p[8:16]))
+	return version, opt, ok, nil
+}
+
+// ParsePreamble 解析客户端发送的前导，返回其中的 Option，用于在连接之外观察协议，例如 geerpctest。
+// p 的长度至少为 PreambleSize，编码类型或压缩算法不支持时返回错误。
+func ParsePreamble(p []byte) (*Option, error) {
+	if len(p) < PreambleSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	_, opt, ok, err := readPreamble(bytes.NewReader(p[:PreambleSize]))
+	if err != nil {
+		return nil, err
+	}
+	if opt.CodecType == "" {
+		return nil, ErrUnknownCodec
+	}
+	if !ok {
+		return nil, ErrUnknownCompress
+	}
+	return opt, nil
+}
+
+// writeHandshakeStatus 服务端回复握手结果
+func writeHandshakeStatus(w io.Writer, status byte) error {
+	_, err := w.Write([]byte{ProtocolVersion, status})
+	return err
+}
+
+// readHandshakeStatus 客户端读取握手结果
+func readHandshakeStatus(r io.Reader) error {
+	var p [2]byte
+	if _, err := io.ReadFull(r, p[:]); err != nil {
+		return err
+	}
+
+	switch p[1] {
+	case handshakeOK:
+		return nil
+	case handshakeUnsupportedVersion:
+		return fmt.Errorf("%w, server supports version %d", ErrUnsupportedVersion, p[0])
+	case handshakeUnknownCodec:
+		return ErrUnknownCodec
+	case handshakeUnknownCompress:
+		return ErrUnknownCompress
+	default:
+		return fmt.Errorf("%w %d", errUnexpectedHandshake, p[1])
+	}
+}
+
+// setCompressor 为握手成功的连接设置协商的压缩算法
+func setCompressor(cc codec.Codec, opt *Option) error {
+	if opt.Compress == codec.NoCompress {
+		return nil
+	}
+
+	c, ok := cc.(codec.CompressibleCodec)
+	if !ok {
+		return fmt.Errorf("rpc: codec %s does not support compression", opt.CodecType)
+	}
+	c.SetCompressor(codec.Compressors[opt.Compress], opt.CompressThreshold)
+	return nil
+}
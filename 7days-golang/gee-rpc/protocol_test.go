@@ -0,0 +1,125 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"geerpc/codec"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	t.Run("unsupported version", func(t *testing.T) {
+		conn, _ := net.Dial("tcp", l.Addr().String())
+		defer func() { _ = conn.Close() }()
+
+		_ = writePreamble(conn, ProtocolVersion+1, DefaultOption)
+		if err := readHandshakeStatus(conn); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("expect ErrUnsupportedVersion, but got %v", err)
+		}
+	})
+
+	t.Run("unknown codec", func(t *testing.T) {
+		conn, _ := net.Dial("tcp", l.Addr().String())
+		defer func() { _ = conn.Close() }()
+
+		p := make([]byte, PreambleSize)
+		_ = writePreamble(&fixedWriter{p}, ProtocolVersion, DefaultOption)
+		p[5] = 0xff
+		_, _ = conn.Write(p)
+		if err := readHandshakeStatus(conn); !errors.Is(err, ErrUnknownCodec) {
+			t.Fatalf("expect ErrUnknownCodec, but got %v", err)
+		}
+	})
+
+	t.Run("unknown compress", func(t *testing.T) {
+		conn, _ := net.Dial("tcp", l.Addr().String())
+		defer func() { _ = conn.Close() }()
+
+		p := make([]byte, PreambleSize)
+		_ = writePreamble(&fixedWriter{p}, ProtocolVersion, DefaultOption)
+		p[6] = 0xff
+		_, _ = conn.Write(p)
+		if err := readHandshakeStatus(conn); !errors.Is(err, ErrUnknownCompress) {
+			t.Fatalf("expect ErrUnknownCompress, but got %v", err)
+		}
+	})
+
+	t.Run("invalid magic number", func(t *testing.T) {
+		conn, _ := net.Dial("tcp", l.Addr().String())
+		defer func() { _ = conn.Close() }()
+
+		_ = writePreamble(conn, ProtocolVersion, &Option{MagicNumber: 1, CodecType: DefaultOption.CodecType})
+		if err := readHandshakeStatus(conn); err == nil {
+			t.Fatal("connection should be closed")
+		}
+	})
+}
+
+// fixedWriter 写入固定长度的 buffer
+type fixedWriter struct {
+	p []byte
+}
+
+func (w *fixedWriter) Write(p []byte) (int, error) {
+	return copy(w.p, p), nil
+}
+
+func TestUndecodableBody(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	for _, opt := range []*Option{DefaultOption, {CodecType: "application/json"}} {
+		client, err := Dial("tcp", l.Addr().String(), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 参数类型错误的 body 被跳过，不影响后续请求
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", "not args", &reply); err == nil {
+			t.Fatalf("%s: expect a decode error", opt.CodecType)
+		}
+
+		if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("%s: expect 3, but got %d, err: %v", opt.CodecType, reply, err)
+		}
+
+		if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, new(string)); err == nil || !strings.Contains(err.Error(), "reading body") {
+			t.Fatalf("%s: expect a reading body error, but got %v", opt.CodecType, err)
+		}
+		_ = client.Close()
+	}
+}
+
+func TestCompression(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	for _, compress := range []codec.CompressType{codec.GzipCompress, codec.LZCompress} {
+		for _, opt := range []*Option{{Compress: compress}, {CodecType: codec.JsonType, Compress: compress, CompressThreshold: 1}} {
+			client, err := Dial("tcp", l.Addr().String(), opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var reply int
+			if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+				t.Fatalf("%s/%s: expect 3, but got %d, err: %v", opt.CodecType, compress, reply, err)
+			}
+			_ = client.Close()
+		}
+	}
+
+	if _, err := Dial("tcp", l.Addr().String(), &Option{Compress: "zstd"}); !errors.Is(err, ErrUnknownCompress) {
+		t.Fatalf("expect ErrUnknownCompress, but got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// trace 返回带调用栈的信息，用于打印 panic 的位置
+func trace(message string) string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip first 3 caller
+
+	var str strings.Builder
+	str.WriteString(message + "\nTraceback:")
+	for _, pc := range pcs[:n] {
+		fn := runtime.FuncForPC(pc)
+		file, line := fn.FileLine(pc)
+		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
+	}
+	return str.String()
+}
+
+// panicError 将 panic 转换为返回给调用方的错误，包含出错的方法名
+func panicError(serviceMethod string, v any) error {
+	return fmt.Errorf("rpc server: panic in %s: %v", serviceMethod, v)
+}
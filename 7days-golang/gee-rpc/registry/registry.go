@@ -0,0 +1,150 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package registry
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GeeRegistry 一个简单的注册中心，提供以下功能：
+//   - 添加服务实例，接收心跳以保持实例存活
+//   - 返回所有存活的服务实例，同时删除已过期的实例
+type GeeRegistry struct {
+	timeout time.Duration          // timeout 实例的存活时间，超过该时间没有心跳则视为过期，0 表示不过期
+	mu      sync.Mutex             // mu 保护 servers
+	servers map[string]*ServerItem // servers key 为实例的 rpcAddr
+}
+
+// ServerItem 一个服务实例
+type ServerItem struct {
+	Addr  string    // Addr 实例的 rpcAddr，例如：tcp@127.0.0.1:9999
+	start time.Time // start 最后一次心跳的时间
+}
+
+const (
+	defaultPath    = "/_geerpc_/registry" // defaultPath 注册中心默认的 HTTP 路径
+	defaultTimeout = time.Minute * 5      // defaultTimeout 默认的实例存活时间
+)
+
+// New 创建一个注册中心，timeout 为实例的存活时间
+func New(timeout time.Duration) *GeeRegistry {
+	return &GeeRegistry{
+		servers: make(map[string]*ServerItem),
+		timeout: timeout,
+	}
+}
+
+var DefaultGeeRegister = New(defaultTimeout)
+
+// putServer 添加实例，已存在则更新心跳时间
+func (r *GeeRegistry) putServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.servers[addr]
+	if s == nil {
+		r.servers[addr] = &ServerItem{Addr: addr, start: time.Now()}
+	} else {
+		s.start = time.Now()
+	}
+}
+
+// aliveServers 返回存活的实例，并删除已过期的实例
+func (r *GeeRegistry) aliveServers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var alive []string
+	for addr, s := range r.servers {
+		if r.timeout == 0 || s.start.Add(r.timeout).After(time.Now()) {
+			alive = append(alive, addr)
+		} else {
+			delete(r.servers, addr)
+		}
+	}
+	sort.Strings(alive)
+	return alive
+}
+
+// ServeHTTP 运行在 defaultPath 上，为了简单，信息都放在 HTTP Header 中：
+//   - GET 返回所有存活的实例，放在 X-Geerpc-Servers 中，用逗号分隔
+//   - POST 添加实例或发送心跳，实例放在 X-Geerpc-Server 中
+func (r *GeeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		w.Header().Set("X-Geerpc-Servers", strings.Join(r.aliveServers(), ","))
+	case "POST":
+		addr := req.Header.Get("X-Geerpc-Server")
+		if addr == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.putServer(addr)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleHTTP 在 registryPath 上注册 HTTP 处理
+func (r *GeeRegistry) HandleHTTP(registryPath string) {
+	http.Handle(registryPath, r)
+	log.Println("rpc registry path:", registryPath)
+}
+
+// HandleHTTP 在 defaultPath 上注册 DefaultGeeRegister
+func HandleHTTP() {
+	DefaultGeeRegister.HandleHTTP(defaultPath)
+}
+
+// Heartbeat 向注册中心注册 addr，并每隔 duration 发送一次心跳，返回的 stop 用于停止发送。
+// duration 为 0 时使用默认值，保证在实例过期前发送下一次心跳。
+func Heartbeat(registry, addr string, duration time.Duration) (stop func()) {
+	if duration == 0 {
+		duration = defaultTimeout - time.Duration(1)*time.Minute
+	}
+
+	done := make(chan struct{})
+	_ = sendHeartbeat(registry, addr)
+	go func() {
+		t := time.NewTicker(duration)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				// 注册中心可能暂时不可用，出错后继续发送
+				_ = sendHeartbeat(registry, addr)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// sendHeartbeat 发送一次心跳
+func sendHeartbeat(registry, addr string) error {
+	log.Println(addr, "send heart beat to registry", registry)
+	httpClient := &http.Client{Timeout: time.Second * 10}
+	req, _ := http.NewRequest("POST", registry, nil)
+	req.Header.Set("X-Geerpc-Server", addr)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		log.Println("rpc server: heart beat err:", err)
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getServers(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.Header.Get("X-Geerpc-Servers")
+}
+
+func TestGeeRegistry_aliveServers(t *testing.T) {
+	r := New(time.Millisecond * 100)
+	r.putServer("tcp@b")
+	r.putServer("tcp@a")
+
+	if servers := r.aliveServers(); !reflect.DeepEqual(servers, []string{"tcp@a", "tcp@b"}) {
+		t.Fatalf("expect [tcp@a tcp@b], but got %v", servers)
+	}
+
+	time.Sleep(time.Millisecond * 150)
+	if servers := r.aliveServers(); len(servers) != 0 {
+		t.Fatalf("servers should be expired, but got %v", servers)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	ts := httptest.NewServer(New(time.Millisecond * 300))
+	defer ts.Close()
+
+	stop := Heartbeat(ts.URL, "tcp@127.0.0.1:9999", time.Millisecond*100)
+	if servers := getServers(t, ts.URL); servers != "tcp@127.0.0.1:9999" {
+		t.Fatalf("expect tcp@127.0.0.1:9999, but got %s", servers)
+	}
+
+	// 心跳期间实例保持存活
+	time.Sleep(time.Millisecond * 500)
+	if servers := getServers(t, ts.URL); servers != "tcp@127.0.0.1:9999" {
+		t.Fatalf("server should be kept alive by heartbeat, but got %s", servers)
+	}
+
+	// 停止心跳后实例过期
+	stop()
+	time.Sleep(time.Millisecond * 500)
+	if servers := getServers(t, ts.URL); servers != "" {
+		t.Fatalf("server should be expired, but got %s", servers)
+	}
+}
@@ -5,39 +5,146 @@
 package geerpc
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"geerpc/codec"
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 )
 
 const MagicNumber = 0x3bef5c
 
+const (
+	connected        = "200 Connected to Gee RPC" // connected CONNECT 成功后返回的状态
+	defaultRPCPath   = "/_geerpc_"                // defaultRPCPath HTTP 方式建立 RPC 连接的路径
+	defaultDebugPath = "/debug/geerpc"            // defaultDebugPath 调试页面的路径
+)
+
 type Option struct {
 	// MagicNumber 标记一个请求
 	MagicNumber int
 	// CodecType 消息编码类型
 	CodecType codec.Type
+	// ConnectTimeout 建立连接的超时时间，0 表示不限制
+	ConnectTimeout time.Duration
+	// HandleTimeout 服务端处理请求的超时时间，0 表示不限制
+	HandleTimeout time.Duration
+	// Compress 帧的压缩算法，握手时协商，为空表示不压缩
+	Compress codec.CompressType
+	// CompressThreshold 客户端压缩请求的阈值，不大于 0 时使用 codec.DefaultCompressThreshold。
+	// 该值不随握手发送，服务端的响应使用 codec.DefaultCompressThreshold。
+	CompressThreshold int
+	// TLSConfig 客户端使用 TLS 连接服务端，ServerName 为空时使用连接地址的 host。
+	// 服务端使用 AcceptTLS，不读取该字段。
+	TLSConfig *tls.Config
 }
 
 var DefaultOption = &Option{
-	MagicNumber: MagicNumber,
-	CodecType:   codec.GobType,
+	MagicNumber:    MagicNumber,
+	CodecType:      codec.GobType,
+	ConnectTimeout: time.Second * 10,
 }
 
-type Server struct{}
+type Server struct {
+	serviceMap   sync.Map                 // serviceMap 已注册的服务，key 为服务名，value 为 *service
+	interceptors []UnaryServerInterceptor // interceptors 服务端拦截器
+	interceptor  UnaryServerInterceptor   // interceptor 合并后的拦截器，没有拦截器时为 nil
+
+	limiter        *limiter // limiter 整个服务的限制，nil 表示不限制
+	connLimit      Limit    // connLimit 每个连接的限制
+	methodLimiters sync.Map // methodLimiters 每个方法的限制，key 为 ServiceMethod，value 为 *limiter
+
+	mu        sync.Mutex                // mu 保护以下字段
+	listeners map[net.Listener]struct{} // listeners Accept 中的 listener
+	conns     map[*serverConn]struct{}  // conns 正在服务的连接
+	shutdown  bool                      // shutdown 已经调用了 Shutdown
+}
 
 // NewServer 初始化一个服务
 func NewServer() *Server {
-	return &Server{}
+	return &Server{
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*serverConn]struct{}),
+	}
 }
 
 var DefaultServer = NewServer()
 
+// Use 添加服务端拦截器，按添加的顺序执行。需要在开始处理请求前调用。
+func (server *Server) Use(interceptors ...UnaryServerInterceptor) {
+	server.interceptors = append(server.interceptors, interceptors...)
+	server.interceptor = chainUnaryServerInterceptors(server.interceptors)
+}
+
+// Register 注册服务，rcvr 的导出方法中满足以下形式的会被注册为 RPC 方法：
+//
+//	func (t *T) MethodName(argv T1, replyv *T2) error
+//	func (t *T) MethodName(ctx context.Context, argv T1, replyv *T2) error
+//
+// replyv 为 *Stream 时注册为流式方法，见 Stream。
+func (server *Server) Register(rcvr any) error {
+	return server.register(rcvr, "")
+}
+
+// RegisterName 和 Register 相同，但使用 name 作为服务名而不是 rcvr 的类型名
+func (server *Server) RegisterName(name string, rcvr any) error {
+	return server.register(rcvr, name)
+}
+
+// register 注册服务，name 为空时使用 rcvr 的类型名
+func (server *Server) register(rcvr any, name string) error {
+	s, err := newService(rcvr, name)
+	if err != nil {
+		return err
+	}
+
+	if _, dup := server.serviceMap.LoadOrStore(s.name, s); dup {
+		return errors.New("rpc: service already defined: " + s.name)
+	}
+	return nil
+}
+
+// Register 注册服务到 DefaultServer
+func Register(rcvr any) error {
+	return DefaultServer.Register(rcvr)
+}
+
+// RegisterName 使用指定的服务名注册服务到 DefaultServer
+func RegisterName(name string, rcvr any) error {
+	return DefaultServer.RegisterName(name, rcvr)
+}
+
+// findService 根据 "Service.Method" 找到对应的 service 和 methodType
+func (server *Server) findService(serviceMethod string) (svc *service, mType *methodType, err error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		err = errors.New("rpc server: service/method request ill-formed: " + serviceMethod)
+		return
+	}
+
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
+	svci, ok := server.serviceMap.Load(serviceName)
+	if !ok {
+		err = errors.New("rpc server: can't find service " + serviceName)
+		return
+	}
+
+	svc = svci.(*service)
+	mType = svc.method[methodName]
+	if mType == nil {
+		err = errors.New("rpc server: can't find method " + methodName)
+	}
+	return
+}
+
 // request 请求类
 type request struct {
 	// h 头信息
@@ -48,6 +155,9 @@ type request struct {
 	//		argv reflect.Value 请求的参数
 	//		replyv reflect.Value 返回的参数
 	argv, replyv reflect.Value
+
+	mType *methodType // mType 请求的方法
+	svc   *service    // svc 请求的服务
 }
 
 // readRequestHeader 读取请求头信息
@@ -62,28 +172,65 @@ func (server *Server) readRequestHeader(cc codec.Codec) (*codec.Header, error) {
 	return &h, nil
 }
 
-// readRequest 读取请求中的 head 和 body 信息
-func (server *Server) readRequest(cc codec.Codec) (*request, error) {
+// readRequest 读取请求中的 head 和 body 信息，读到 head 后通过 sc.begin 标记连接正在处理请求，
+// 返回的 req 不为 nil 时调用方需要在处理完成后调用 sc.end
+func (server *Server) readRequest(cc codec.Codec, sc *serverConn) (*request, error) {
 	h, err := server.readRequestHeader(cc)
 
 	if err != nil {
 		return nil, err
 	}
+	sc.begin()
 
 	req := &request{h: h}
 
-	// TODO 参数类型位置，目前只处理字符串类型
-	req.argv = reflect.New(reflect.TypeOf(""))
+	// 流中的消息、结束帧和窗口，body 由 serveCodec 根据所属的流读取
+	if isStreamFrame(h.Flag) {
+		return req, nil
+	}
+
+	req.svc, req.mType, err = server.findService(h.ServiceMethod)
+	if err != nil {
+		// 丢弃请求的 body，保证后续请求能正常读取
+		_ = cc.ReadBody(nil)
+		return req, err
+	}
+
+	req.argv = req.mType.newArgv()
+	if !req.mType.stream {
+		req.replyv = req.mType.newReplyv()
+	}
+
+	// ReadBody 需要传入指针
+	argvi := req.argv.Interface()
+	if req.argv.Type().Kind() != reflect.Ptr {
+		argvi = req.argv.Addr().Interface()
+	}
 
-	if err = cc.ReadBody(req.argv.Interface()); err != nil {
-		log.Println("rpc server: read argv err:", err)
+	if err = cc.ReadBody(argvi); err != nil {
+		log.Println("rpc server: read body err:", err)
+		return req, err
+	}
+
+	if req.mType.stream && h.Flag != codec.FlagStreamOpen {
+		return req, errors.New("rpc server: " + h.ServiceMethod + " is a stream method, use Client.NewStream")
+	}
+	if !req.mType.stream && h.Flag == codec.FlagStreamOpen {
+		return req, errors.New("rpc server: " + h.ServiceMethod + " is not a stream method")
 	}
 
 	return req, nil
 }
 
-// sendResponse 返回数据
+// sendResponse 返回数据，单向调用不返回，出错时只打印日志
 func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body any, sending *sync.Mutex) {
+	if h.Flag == codec.FlagOneWay {
+		if h.Error != "" {
+			log.Printf("rpc server: one-way call %s error: %s", h.ServiceMethod, h.Error)
+		}
+		return
+	}
+
 	sending.Lock()
 	defer sending.Unlock()
 
@@ -92,41 +239,256 @@ func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body any, se
 	}
 }
 
-// handleRequest 处理请求
-func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup) {
-	// todo 应调用已注册的 rpc 方法以获取正确的 replyv ，目前只需要打印 argv 并发送 hello 消息
+// handleRequest 处理请求，base 携带连接的对端信息
+// timeout 为 0 时不限制处理时间，否则超时后直接返回超时错误，方法的执行结果会被丢弃。
+// release 在方法执行完成时调用，超时返回后方法仍然占用并发数。
+func (server *Server) handleRequest(base context.Context, cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup, timeout time.Duration, release func()) {
 	defer wg.Done()
-	log.Println("请求的 req 头和内容：", req.h, req.argv.Elem())
-	req.replyv = reflect.ValueOf(fmt.Sprintf("geerpc rep %d", req.h.Seq))
-	server.sendResponse(cc, req.h, req.replyv.Interface(), sending)
+
+	// 超时的 ctx 会传给拦截器和方法，客户端传来的剩余超时时间比 timeout 短时以客户端为准
+	md, remaining := parseIncomingMetadata(req.h.Metadata)
+	if remaining > 0 && (timeout == 0 || remaining < timeout) {
+		timeout = remaining
+	}
+
+	ctx, cancel := withTimeout(newIncomingContext(base, md), timeout)
+	defer cancel()
+
+	// 带缓冲，超时返回后方法执行完成也不会阻塞
+	called := make(chan callResult, 1)
+	go func() {
+		defer release()
+		// 方法 panic 时只影响当前请求，连接上的其他请求继续处理
+		defer func() {
+			if v := recover(); v != nil {
+				err := panicError(req.h.ServiceMethod, v)
+				log.Printf("%s\n\n", trace(err.Error()))
+				called <- callResult{err: err}
+			}
+		}()
+
+		reply, err := server.invoke(ctx, req, timeout)
+		called <- callResult{reply: reply, err: err}
+	}()
+
+	var result callResult
+	select {
+	case result = <-called:
+	case <-ctx.Done():
+		// 方法恰好在超时前完成时以方法的结果为准
+		select {
+		case result = <-called:
+		default:
+			result.err = handleTimeoutError(timeout)
+		}
+	}
+
+	if result.err != nil {
+		req.h.Error = result.err.Error()
+		server.sendResponse(cc, req.h, invalidRequest, sending)
+		return
+	}
+	server.sendResponse(cc, req.h, result.reply, sending)
+}
+
+// withTimeout timeout 大于 0 时返回带超时的 ctx，否则只能手动取消
+func withTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// callResult invoke 的执行结果，用于在超时控制中传递
+type callResult struct {
+	reply any
+	err   error
+}
+
+// handleTimeoutError 处理超时时返回给客户端的错误
+func handleTimeoutError(timeout time.Duration) error {
+	return fmt.Errorf("rpc server: request handle timeout: expect within %s", timeout)
+}
+
+// invoke 经过拦截器调用请求对应的方法。
+// 方法在超时后才返回时，客户端已经收到超时错误，拦截器看到的同样是超时错误。
+func (server *Server) invoke(ctx context.Context, req *request, timeout time.Duration) (any, error) {
+	handler := func(ctx context.Context, argv any) (any, error) {
+		// 拦截器可能替换了参数
+		argvv := reflect.ValueOf(argv)
+		if argvv.Type() != req.argv.Type() {
+			return nil, fmt.Errorf("rpc server: invalid argument type %s, expect %s", argvv.Type(), req.argv.Type())
+		}
+		err := req.svc.call(ctx, req.mType, argvv, req.replyv)
+		if err == nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, handleTimeoutError(timeout)
+		}
+		return req.replyv.Interface(), err
+	}
+
+	if server.interceptor == nil {
+		return handler(ctx, req.argv.Interface())
+	}
+
+	info := &UnaryServerInfo{ServiceMethod: req.h.ServiceMethod, Seq: req.h.Seq}
+	return server.interceptor(ctx, req.argv.Interface(), info, handler)
+}
+
+// handleStream 处理流式请求，方法返回后发送结束帧，流式请求不受 HandleTimeout 和拦截器的限制
+func (server *Server) handleStream(req *request, stream *Stream, streams *streamSet, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.cancel()
+	defer streams.remove(req.h.Seq)
+
+	err := func() (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = panicError(req.h.ServiceMethod, v)
+				log.Printf("%s\n\n", trace(err.Error()))
+			}
+		}()
+		return req.svc.call(stream.ctx, req.mType, req.argv, reflect.ValueOf(stream))
+	}()
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if err := stream.sendEnd(errMsg); err != nil {
+		log.Println("rpc server: write stream end error:", err)
+	}
+}
+
+// newServerStream 为打开流的请求创建 Stream，ctx 继承连接的对端信息、客户端的元数据和剩余超时时间
+func (server *Server) newServerStream(base context.Context, cc codec.Codec, req *request, sending *sync.Mutex) *Stream {
+	md, remaining := parseIncomingMetadata(req.h.Metadata)
+	ctx, cancel := withTimeout(newIncomingContext(base, md), remaining)
+
+	write := func(h *codec.Header, body any) error {
+		sending.Lock()
+		defer sending.Unlock()
+		return cc.Write(h, body)
+	}
+
+	stream := newStream(ctx, req.h.ServiceMethod, req.h.Seq, req.mType.ArgType, write)
+	stream.cancel = cancel
+	return stream
 }
 
-// serveCodec 根据编码类处理请求内容
-func (server *Server) serveCodec(cc codec.Codec) {
+// readStreamFrame 读取流中的消息、结束帧或窗口，流已经结束时丢弃
+func (server *Server) readStreamFrame(cc codec.Codec, h *codec.Header, streams *streamSet) {
+	stream := streams.get(h.Seq)
+	if stream == nil {
+		_ = cc.ReadBody(nil)
+		return
+	}
+
+	if h.Flag == codec.FlagStreamCredit {
+		if err := stream.readCredit(cc); err != nil {
+			log.Println("rpc server: read stream credit err:", err)
+		}
+		return
+	}
+
+	if h.Flag == codec.FlagStreamMsg {
+		err := stream.readMsg(cc)
+		if err == ErrStreamOverflow {
+			// 客户端没有遵守流量控制，方法的 Recv 返回 ErrStreamOverflow，取消 ctx 让方法尽快返回，结束帧由 handleStream 发送
+			stream.cancel()
+			return
+		}
+		if err != nil {
+			log.Println("rpc server: read stream message err:", err)
+		}
+		return
+	}
+
+	_ = cc.ReadBody(nil)
+	if h.Error != "" {
+		// 客户端取消了流
+		stream.closeRecv(errors.New(h.Error))
+		stream.cancel()
+		return
+	}
+	stream.closeRecv(nil)
+}
+
+// serveCodec 根据编码类处理请求内容，base 为连接上所有请求的 ctx 的父 context
+func (server *Server) serveCodec(base context.Context, cc codec.Codec, opt *Option) {
 	sending := new(sync.Mutex)
 	wg := new(sync.WaitGroup)
+	streams := newStreamSet()
+
+	connLimiter := newLimiter("connection", server.connLimit)
+
+	sc := &serverConn{cc: cc, sending: sending}
+	if !server.trackConn(sc, true) {
+		_ = cc.Close()
+		return
+	}
+	defer server.trackConn(sc, false)
 
 	for {
-		req, err := server.readRequest(cc)
+		req, err := server.readRequest(cc, sc)
 		if err != nil {
 			if req == nil {
 				break
 			}
 
+			// 打开流失败时直接结束流
+			if req.h.Flag == codec.FlagStreamOpen {
+				req.h.Flag = codec.FlagStreamEnd
+			}
+			req.h.Error = err.Error()
+			server.sendResponse(cc, req.h, invalidRequest, sending)
+			sc.end()
+			continue
+		}
+
+		if isStreamFrame(req.h.Flag) {
+			server.readStreamFrame(cc, req.h, streams)
+			sc.end()
+			continue
+		}
+
+		// 超过限制的请求直接拒绝，不创建协程
+		release, err := server.acquire(connLimiter, req.h.ServiceMethod)
+		if err != nil {
+			if req.h.Flag == codec.FlagStreamOpen {
+				req.h.Flag = codec.FlagStreamEnd
+			}
 			req.h.Error = err.Error()
-			server.sendResponse(cc, req.h, req.replyv.Interface(), sending)
+			server.sendResponse(cc, req.h, invalidRequest, sending)
+			sc.end()
 			continue
 		}
+
+		// readRequest 已经调用了 sc.begin，请求处理完成时调用 sc.end
 		wg.Add(1)
-		go server.handleRequest(cc, req, sending, wg)
+		if req.h.Flag == codec.FlagStreamOpen {
+			stream := server.newServerStream(base, cc, req, sending)
+			streams.add(stream)
+			go func() {
+				defer sc.end()
+				defer release()
+				server.handleStream(req, stream, streams, wg)
+			}()
+		} else {
+			go func() {
+				defer sc.end()
+				server.handleRequest(base, cc, req, sending, wg, opt.HandleTimeout, release)
+			}()
+		}
 	}
 
+	// 连接断开，未结束的流不会再收到消息
+	streams.closeAll(io.ErrUnexpectedEOF)
 	wg.Wait()
 	_ = cc.Close()
 }
 
-// invalidRequest is a placeholder for response argv when error occurs
-// var invalidRequest = struct{}{}
+// invalidRequest 发生错误时作为响应 body 的占位
+var invalidRequest = struct{}{}
 
 // ServeConn 处理请求
 func (server *Server) ServeConn(conn io.ReadWriteCloser) {
@@ -134,16 +496,23 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		_ = conn.Close()
 	}()
 
-	var opt Option
+	// TLS 连接先完成握手，对端的证书通过 ctx 传给方法
+	peer, err := newPeer(conn)
+	if err != nil {
+		log.Println("rpc server: tls handshake error:", err)
+		return
+	}
 
-	// 判断 option 格式是否错误 ， 请求时候有 encode DefaultOption
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	// 读取固定长度的前导，MagicNumber 不对说明不是 geerpc 的客户端，直接关闭
+	version, opt, compressOK, err := readPreamble(conn)
+	if err != nil {
 		log.Println("rpc server: options error:", err)
+		return
 	}
 
-	// 不知道为什么加这个逻辑
-	if opt.MagicNumber != MagicNumber {
-		log.Printf("rpc server: invalid magic number %x", opt.MagicNumber)
+	if version != ProtocolVersion {
+		log.Printf("rpc server: unsupported protocol version %d", version)
+		_ = writeHandshakeStatus(conn, handshakeUnsupportedVersion)
 		return
 	}
 
@@ -151,20 +520,48 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	f := codec.NewCodecFuncMap[opt.CodecType]
 	if f == nil {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
+		_ = writeHandshakeStatus(conn, handshakeUnknownCodec)
+		return
+	}
+
+	if !compressOK {
+		log.Println("rpc server: invalid compress type")
+		_ = writeHandshakeStatus(conn, handshakeUnknownCompress)
+		return
+	}
+
+	cc := f(conn)
+	if err := setCompressor(cc, opt); err != nil {
+		log.Println("rpc server:", err)
+		_ = writeHandshakeStatus(conn, handshakeUnknownCompress)
+		return
+	}
+
+	if err := writeHandshakeStatus(conn, handshakeOK); err != nil {
+		log.Println("rpc server: handshake error:", err)
+		return
 	}
 
 	// 执行处理
-	server.serveCodec(f(conn))
+	server.serveCodec(newPeerContext(context.Background(), peer), cc, opt)
 }
 
 // Accept 开启监听并处理请求
 func (server *Server) Accept(lis net.Listener) {
+	if !server.trackListener(lis, true) {
+		_ = lis.Close()
+		return
+	}
+	defer server.trackListener(lis, false)
+
 	for {
 		conn, err := lis.Accept()
 
-		// 如果出错就结束监听
+		// 如果出错就结束监听，Shutdown 关闭 listener 导致的错误不需要打印
 		if err != nil {
-			log.Println("rpc server: accept error:", err)
+			if !server.shuttingDown() {
+				log.Println("rpc server: accept error:", err)
+			}
 			return
 		}
 
@@ -173,7 +570,47 @@ func (server *Server) Accept(lis net.Listener) {
 	}
 }
 
+// AcceptTLS 使用 TLS 监听并处理请求。
+// 需要验证客户端证书（双向 TLS）时设置 config.ClientAuth 为 tls.RequireAndVerifyClientCert 并设置 ClientCAs，
+// 方法中通过 PeerFromContext 获取客户端的身份。
+func (server *Server) AcceptTLS(lis net.Listener, config *tls.Config) {
+	server.Accept(tls.NewListener(lis, config))
+}
+
 // Accept new Server 监听并处理请求
 func Accept(lis net.Listener) {
 	DefaultServer.Accept(lis)
 }
+
+// ServeHTTP 实现 http.Handler，只接受 CONNECT 请求，
+// 劫持（Hijack）HTTP 连接后交给 ServeConn，后续通信就和 TCP 方式一致了。
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Print("rpc hijacking ", req.RemoteAddr, ": ", err.Error())
+		return
+	}
+
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+	server.ServeConn(conn)
+}
+
+// HandleHTTP 在 defaultRPCPath 注册 RPC 的 HTTP 处理，在 defaultDebugPath 注册调试页面。
+// 仍然需要调用 http.Serve 开启 HTTP 服务。
+func (server *Server) HandleHTTP() {
+	http.Handle(defaultRPCPath, server)
+	http.Handle(defaultDebugPath, debugHTTP{server})
+	log.Println("rpc server debug path:", defaultDebugPath)
+}
+
+// HandleHTTP 为 DefaultServer 注册 HTTP 处理
+func HandleHTTP() {
+	DefaultServer.HandleHTTP()
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"geerpc/codec"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestServerRegisterAndCall(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	if err := server.Register(&foo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Register(&foo); err == nil {
+		t.Fatal("register the same service twice should return an error")
+	}
+	if err := server.RegisterName("Calc", &foo); err != nil {
+		t.Fatal(err)
+	}
+
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Calc.Sum", &Args{Num1: 2, Num2: 2}, &reply); err != nil || reply != 4 {
+		t.Fatalf("expect 4, but got %d, err: %v", reply, err)
+	}
+
+	if err := client.Call(context.Background(), "Bar.Sum", &Args{}, &reply); err == nil || !strings.Contains(err.Error(), "can't find service") {
+		t.Fatalf("expect can't find service error, but got %v", err)
+	}
+
+	if err := client.Call(context.Background(), "Foo.Mul", &Args{}, &reply); err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("expect can't find method error, but got %v", err)
+	}
+
+	// 出错后连接仍然可用
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 2, Num2: 2}, &reply); err != nil || reply != 4 {
+		t.Fatalf("expect 4, but got %d, err: %v", reply, err)
+	}
+}
+
+func TestServerJsonCodec(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.Accept(l)
+
+	client, err := Dial("tcp", l.Addr().String(), &Option{CodecType: codec.JsonType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+
+	if err := client.Call(context.Background(), "Foo.Mul", &Args{}, &reply); err == nil {
+		t.Fatal("expect can't find method error")
+	}
+
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 2, Num2: 2}, &reply); err != nil || reply != 4 {
+		t.Fatalf("expect 4, but got %d, err: %v", reply, err)
+	}
+}
+
+func TestServerRecoverPanic(t *testing.T) {
+	_, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply int
+	err = client.Call(context.Background(), "Foo.Panic", &Args{Num1: 1}, &reply)
+	if err == nil || !strings.Contains(err.Error(), "panic in Foo.Panic") {
+		t.Fatalf("expect a panic error with method name, but got %v", err)
+	}
+
+	// 同一个连接上的请求继续处理
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+}
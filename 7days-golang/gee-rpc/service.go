@@ -0,0 +1,162 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"log"
+	"reflect"
+	"sync/atomic"
+)
+
+// methodType 一个可以被 RPC 调用的方法，形如：
+//
+//	func (t *T) MethodName(argv T1, replyv *T2) error
+//	func (t *T) MethodName(ctx context.Context, argv T1, replyv *T2) error
+type methodType struct {
+	method      reflect.Method // method 方法本身
+	ArgType     reflect.Type   // ArgType 参数的类型
+	ReplyType   reflect.Type   // ReplyType 返回值的类型
+	withContext bool           // withContext 第一个参数是否为 context.Context
+	stream      bool           // stream 是否为流式方法，最后一个参数为 *Stream
+	numCalls    uint64         // numCalls 统计方法调用次数
+}
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfStream  = reflect.TypeOf((*Stream)(nil))
+)
+
+// NumCalls 返回方法被调用的次数
+func (m *methodType) NumCalls() uint64 {
+	return atomic.LoadUint64(&m.numCalls)
+}
+
+// newArgv 创建对应类型的参数实例
+// 参数可能是指针类型，也可能是值类型，需要区别处理。
+func (m *methodType) newArgv() reflect.Value {
+	var argv reflect.Value
+	if m.ArgType.Kind() == reflect.Ptr {
+		argv = reflect.New(m.ArgType.Elem())
+	} else {
+		argv = reflect.New(m.ArgType).Elem()
+	}
+	return argv
+}
+
+// newReplyv 创建返回值实例，返回值必须是指针类型，map 和 slice 需要初始化
+func (m *methodType) newReplyv() reflect.Value {
+	replyv := reflect.New(m.ReplyType.Elem())
+	switch m.ReplyType.Elem().Kind() {
+	case reflect.Map:
+		replyv.Elem().Set(reflect.MakeMap(m.ReplyType.Elem()))
+	case reflect.Slice:
+		replyv.Elem().Set(reflect.MakeSlice(m.ReplyType.Elem(), 0, 0))
+	}
+	return replyv
+}
+
+// service 一个被注册的服务，即一个结构体实例
+type service struct {
+	name   string                 // name 映射的结构体的名称，例如：Foo
+	typ    reflect.Type           // typ 结构体的类型
+	rcvr   reflect.Value          // rcvr 结构体的实例本身，调用方法时作为第 0 个参数
+	method map[string]*methodType // method 存储结构体所有符合条件的方法
+}
+
+// newService 根据结构体实例创建 service，name 为空时使用结构体的名称
+func newService(rcvr any, name string) (*service, error) {
+	s := new(service)
+	s.rcvr = reflect.ValueOf(rcvr)
+	s.name = name
+	if s.name == "" {
+		s.name = reflect.Indirect(s.rcvr).Type().Name()
+	}
+	s.typ = reflect.TypeOf(rcvr)
+
+	if !ast.IsExported(s.name) {
+		return nil, fmt.Errorf("rpc server: %s is not a valid service name", s.name)
+	}
+
+	s.registerMethods()
+	if len(s.method) == 0 {
+		return nil, fmt.Errorf("rpc server: type %s has no exported methods of suitable type", s.name)
+	}
+	return s, nil
+}
+
+// registerMethods 过滤出符合条件的方法：
+//   - 两个导出或内置类型的入参（反射时为 3 个，第 0 个是自身），前面可以再加一个 context.Context
+//   - 返回值有且只有 1 个，类型为 error
+//   - 第二个入参为 *Stream 时是流式方法
+func (s *service) registerMethods() {
+	s.method = make(map[string]*methodType)
+
+	for i := 0; i < s.typ.NumMethod(); i++ {
+		method := s.typ.Method(i)
+		mType := method.Type
+
+		if mType.NumOut() != 1 || mType.Out(0) != typeOfError {
+			continue
+		}
+
+		// withContext 时参数整体后移一位
+		withContext := mType.NumIn() == 4 && mType.In(1) == typeOfContext
+		if mType.NumIn() != 3 && !withContext {
+			continue
+		}
+
+		offset := 1
+		if withContext {
+			offset = 2
+		}
+
+		argType, replyType := mType.In(offset), mType.In(offset+1)
+		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+			continue
+		}
+
+		// 返回值需要通过指针写回
+		if replyType.Kind() != reflect.Ptr {
+			continue
+		}
+
+		s.method[method.Name] = &methodType{
+			method:      method,
+			ArgType:     argType,
+			ReplyType:   replyType,
+			withContext: withContext,
+			stream:      replyType == typeOfStream,
+		}
+		log.Printf("rpc server: register %s.%s\n", s.name, method.Name)
+	}
+}
+
+// call 通过反射调用方法，方法需要 context.Context 时传入 ctx
+func (s *service) call(ctx context.Context, m *methodType, argv, replyv reflect.Value) error {
+	atomic.AddUint64(&m.numCalls, 1)
+	f := m.method.Func
+
+	in := []reflect.Value{s.rcvr, argv, replyv}
+	if m.withContext {
+		in = []reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv}
+	}
+	returnValues := f.Call(in)
+	if errInter := returnValues[0].Interface(); errInter != nil {
+		return errInter.(error)
+	}
+	return nil
+}
+
+// isExportedOrBuiltinType 是否为导出类型或者内置类型
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return ast.IsExported(t.Name()) || t.PkgPath() == ""
+}
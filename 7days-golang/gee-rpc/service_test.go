@@ -0,0 +1,70 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Foo int
+
+type Args struct{ Num1, Num2 int }
+
+func (f Foo) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+// sum 未导出，不应被注册
+func (f Foo) sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func TestNewService(t *testing.T) {
+	var foo Foo
+	s, err := newService(&foo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.method) != 3 {
+		t.Fatalf("wrong service Method, expect 3, but got %d", len(s.method))
+	}
+
+	if s.method["Sum"] == nil {
+		t.Fatal("wrong Method, Sum shouldn't nil")
+	}
+}
+
+func TestMethodTypeCall(t *testing.T) {
+	var foo Foo
+	s, _ := newService(&foo, "")
+	mType := s.method["Sum"]
+
+	argv := mType.newArgv()
+	replyv := mType.newReplyv()
+	argv.Set(reflect.ValueOf(Args{Num1: 1, Num2: 3}))
+	err := s.call(context.Background(), mType, argv, replyv)
+
+	if err != nil || *replyv.Interface().(*int) != 4 || mType.NumCalls() != 1 {
+		t.Fatal("failed to call Foo.Sum")
+	}
+}
+
+func (f Foo) Sleep(args Args, reply *int) error {
+	time.Sleep(time.Second * time.Duration(args.Num1))
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func (f Foo) Panic(args Args, reply *int) error {
+	var m map[string]int
+	m["geerpc"] = args.Num1
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"geerpc/codec"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownPollInterval Shutdown 检查连接是否处理完请求的间隔
+const shutdownPollInterval = time.Millisecond * 10
+
+// serverConn 服务端的一个连接，Shutdown 通过它通知客户端并等待请求处理完
+type serverConn struct {
+	cc       codec.Codec
+	sending  *sync.Mutex // sending 和 serveCodec 共用的发送锁
+	inFlight int64       // inFlight 已经读到请求头、还没有处理完的请求和流的数量，原子操作
+}
+
+// goAway 通知客户端服务端即将关闭，不要再发送新的请求
+func (sc *serverConn) goAway() {
+	sc.sending.Lock()
+	defer sc.sending.Unlock()
+
+	if err := sc.cc.Write(&codec.Header{Flag: codec.FlagShutdown}, invalidRequest); err != nil {
+		log.Println("rpc server: write shutdown error:", err)
+	}
+}
+
+// begin 读到一个请求头，Shutdown 不会关闭正在读取请求 body 的连接
+func (sc *serverConn) begin() {
+	atomic.AddInt64(&sc.inFlight, 1)
+}
+
+// end 一个请求处理完成
+func (sc *serverConn) end() {
+	atomic.AddInt64(&sc.inFlight, -1)
+}
+
+// idle 没有正在处理的请求
+func (sc *serverConn) idle() bool {
+	return atomic.LoadInt64(&sc.inFlight) == 0
+}
+
+// trackListener 记录或移除 Accept 中的 listener，关闭后返回 false
+func (server *Server) trackListener(lis net.Listener, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if !add {
+		delete(server.listeners, lis)
+		return true
+	}
+	if server.shutdown {
+		return false
+	}
+	server.listeners[lis] = struct{}{}
+	return true
+}
+
+// trackConn 记录或移除连接，关闭后返回 false
+func (server *Server) trackConn(sc *serverConn, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if !add {
+		delete(server.conns, sc)
+		return true
+	}
+	if server.shutdown {
+		return false
+	}
+	server.conns[sc] = struct{}{}
+	return true
+}
+
+// shuttingDown 是否已经调用了 Shutdown
+func (server *Server) shuttingDown() bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.shutdown
+}
+
+// closeIdleConns 关闭已经处理完请求的连接，返回是否所有连接都已关闭
+func (server *Server) closeIdleConns(force bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for sc := range server.conns {
+		if force || sc.idle() {
+			_ = sc.cc.Close()
+			delete(server.conns, sc)
+		}
+	}
+	return len(server.conns) == 0
+}
+
+// Shutdown 优雅地关闭服务：
+//   - 关闭所有的 listener，不再接受新的连接
+//   - 通知已连接的客户端不再发送新的请求，客户端之后的调用返回 ErrServerShutdown
+//   - 等待正在处理的请求和流结束后关闭连接
+//
+// ctx 结束时强制关闭剩余的连接并返回 ctx.Err()。
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+	server.shutdown = true
+	for lis := range server.listeners {
+		_ = lis.Close()
+		delete(server.listeners, lis)
+	}
+	conns := make([]*serverConn, 0, len(server.conns))
+	for sc := range server.conns {
+		conns = append(conns, sc)
+	}
+	server.mu.Unlock()
+
+	for _, sc := range conns {
+		sc.goAway()
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if server.closeIdleConns(false) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			server.closeIdleConns(true)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"geerpc/codec"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	server, l := startServer(t)
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// 正在处理的请求在关闭前完成
+	done := make(chan error, 1)
+	go func() {
+		var reply int
+		done <- client.Call(context.Background(), "Foo.Sleep", &Args{Num1: 1, Num2: 1}, &reply)
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight call should succeed, but got %v", err)
+	}
+
+	if _, err := Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatal("listener should be closed")
+	}
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != ErrServerShutdown {
+		t.Fatalf("expect ErrServerShutdown, but got %v", err)
+	}
+	if client.IsAvailable() {
+		t.Fatal("client should not be available after server shutdown")
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	server, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	client, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	done := make(chan error, 1)
+	go func() {
+		var reply int
+		done <- client.Call(context.Background(), "Foo.Sleep", &Args{Num1: 2}, &reply)
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect context.DeadlineExceeded, but got %v", err)
+	}
+
+	// 强制关闭的连接上未完成的请求返回 ErrServerShutdown
+	if err := <-done; err != ErrServerShutdown {
+		t.Fatalf("expect ErrServerShutdown, but got %v", err)
+	}
+}
+
+// bufferConn 把 Codec 写入的数据保存在 Buffer 中
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (c *bufferConn) Close() error { return nil }
+
+func TestServer_ShutdownPartialRequest(t *testing.T) {
+	server, l := startServer(t)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = writePreamble(conn, ProtocolVersion, DefaultOption)
+	if err := readHandshakeStatus(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	// 请求头和 body 分两次发送，Shutdown 在两次之间开始
+	var buf bufferConn
+	_ = codec.NewGobCodec(&buf).Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 1}, &Args{Num1: 1, Num2: 2})
+	msg := buf.Bytes()
+	hn, _ := codec.FrameSize(msg)
+	_, _ = conn.Write(msg[:hn])
+	time.Sleep(time.Millisecond * 50)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(ctx) }()
+	time.Sleep(time.Millisecond * 50)
+	_, _ = conn.Write(msg[hn:])
+
+	// 读取了一半的请求仍然会被处理，跳过关闭通知
+	cc := codec.NewGobCodec(conn)
+	var h codec.Header
+	for h.Seq != 1 {
+		if err := cc.ReadHeader(&h); err != nil {
+			t.Fatalf("expect the partial request to be served, got %v", err)
+		}
+		if h.Seq != 1 {
+			_ = cc.ReadBody(nil)
+		}
+	}
+	var reply int
+	if err := cc.ReadBody(&reply); err != nil || h.Error != "" || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v %s", reply, err, h.Error)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"geerpc/codec"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// streamBufferSize 每个流缓存的对端消息数，也是流量控制的窗口大小：
+// 发送方最多有 streamBufferSize 条对端没有 Recv 的消息，超过时 Send 等待对端的 FlagStreamCredit，
+// 所以缓存不会满，一个读取缓慢的流不会阻塞连接上的其他调用和流。
+const streamBufferSize = 64
+
+// streamCreditBatch 接收方每 Recv 这么多条消息发送一次 FlagStreamCredit
+const streamCreditBatch = streamBufferSize / 2
+
+var ErrStreamClosed = errors.New("rpc: stream is closed")
+
+// ErrStreamOverflow 对端没有遵守流量控制，缓存的消息超过 streamBufferSize，流被结束
+var ErrStreamOverflow = errors.New("rpc: stream receive buffer overflow")
+
+// Stream 一个流式调用，和普通调用复用同一个连接，通过 Seq 区分。
+//
+// 服务端的流式方法形如：
+//
+//	func (t *T) MethodName(argv T1, stream *geerpc.Stream) error
+//	func (t *T) MethodName(ctx context.Context, argv T1, stream *geerpc.Stream) error
+//
+// argv 为客户端打开流时发送的参数，之后客户端发送的消息类型同样为 T1，通过 Recv 读取；
+// 服务端通过 Send 发送消息，方法返回即结束流，返回的错误会传给客户端。
+// 客户端通过 Client.NewStream 打开流，发送完毕后调用 CloseSend，Recv 返回 io.EOF 表示服务端正常结束。
+type Stream struct {
+	ctx           context.Context
+	serviceMethod string
+	seq           uint64
+	recvType      reflect.Type                          // recvType 对端消息的类型（非指针）
+	write         func(h *codec.Header, body any) error // write 发送一帧，需要自己处理并发
+
+	cancel context.CancelFunc // cancel 取消 ctx，服务端在方法返回或客户端取消时调用，客户端为 nil
+
+	msgs chan reflect.Value // msgs 收到的对端消息，元素为指向 recvType 的指针
+	done chan struct{}      // done 对端结束流或流被取消时关闭
+	once sync.Once
+	err  error // err 结束的原因，io.EOF 表示正常结束
+
+	mu         sync.Mutex    // mu 保护 sendClosed
+	sendClosed bool          // sendClosed 本端不再发送
+	sendDone   chan struct{} // sendDone sendClosed 置为 true 时关闭，唤醒等待窗口的 Send
+
+	cmu         sync.Mutex    // cmu 保护 credits 和 consumed
+	credits     int           // credits 还可以发送的消息数
+	consumed    int           // consumed 已经 Recv 但还没有通知对端的消息数
+	creditReady chan struct{} // creditReady 收到对端的 FlagStreamCredit 时通知等待的 Send
+}
+
+// newStream 创建 Stream，recvType 为对端消息的类型
+func newStream(ctx context.Context, serviceMethod string, seq uint64, recvType reflect.Type, write func(h *codec.Header, body any) error) *Stream {
+	for recvType.Kind() == reflect.Ptr {
+		recvType = recvType.Elem()
+	}
+
+	return &Stream{
+		ctx:           ctx,
+		serviceMethod: serviceMethod,
+		seq:           seq,
+		recvType:      recvType,
+		write:         write,
+		msgs:          make(chan reflect.Value, streamBufferSize),
+		done:          make(chan struct{}),
+		sendDone:      make(chan struct{}),
+		credits:       streamBufferSize,
+		creditReady:   make(chan struct{}, 1),
+	}
+}
+
+// Context 返回流的 context，服务端可以从中获取元数据
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Send 发送一条消息，对端未读取的消息达到 streamBufferSize 时等待对端读取
+func (s *Stream) Send(msg any) error {
+	if err := s.acquireCredit(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendClosed {
+		return ErrStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.write(&codec.Header{ServiceMethod: s.serviceMethod, Seq: s.seq, Flag: codec.FlagStreamMsg}, msg)
+}
+
+// Recv 读取对端的一条消息，msg 必须是指向消息类型的指针。
+// 对端正常结束时返回 io.EOF，出错时返回对端的错误。
+func (s *Stream) Recv(msg any) error {
+	v := reflect.ValueOf(msg)
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("rpc: stream message must be a non-nil *%s", s.recvType)
+	}
+	if v.Type() != reflect.PtrTo(s.recvType) {
+		return fmt.Errorf("rpc: stream message type %s, expect *%s", v.Type(), s.recvType)
+	}
+
+	select {
+	case m := <-s.msgs:
+		v.Elem().Set(m.Elem())
+		s.grantCredit()
+		return nil
+	case <-s.done:
+		// 结束前收到的消息仍然需要读完
+		select {
+		case m := <-s.msgs:
+			v.Elem().Set(m.Elem())
+			return nil
+		default:
+			return s.err
+		}
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// acquireCredit 占用一个发送窗口，窗口用完时等待对端的 FlagStreamCredit
+func (s *Stream) acquireCredit() error {
+	for {
+		s.cmu.Lock()
+		if s.credits > 0 {
+			s.credits--
+			s.cmu.Unlock()
+			return nil
+		}
+		s.cmu.Unlock()
+
+		select {
+		case <-s.creditReady:
+		case <-s.sendDone:
+			return ErrStreamClosed
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// addCredit 由连接的读取协程调用，对端又允许发送 n 条消息
+func (s *Stream) addCredit(n int) {
+	s.cmu.Lock()
+	s.credits += n
+	s.cmu.Unlock()
+
+	select {
+	case s.creditReady <- struct{}{}:
+	default:
+	}
+}
+
+// grantCredit Recv 读取一条消息后调用，累计 streamCreditBatch 条后通知对端可以继续发送
+func (s *Stream) grantCredit() {
+	s.cmu.Lock()
+	s.consumed++
+	n := s.consumed
+	if n < streamCreditBatch {
+		s.cmu.Unlock()
+		return
+	}
+	s.consumed = 0
+	s.cmu.Unlock()
+
+	// 本端不再发送时对端仍然可能在发送，同样需要通知；出错说明连接已经断开，忽略
+	_ = s.write(&codec.Header{ServiceMethod: s.serviceMethod, Seq: s.seq, Flag: codec.FlagStreamCredit}, n)
+}
+
+// CloseSend 客户端告诉服务端不再发送消息，之后仍然可以 Recv。
+// 服务端通过方法返回结束流，不需要调用。
+func (s *Stream) CloseSend() error {
+	return s.sendEnd("")
+}
+
+// sendEnd 发送结束帧，errMsg 不为空时告诉对端因出错而结束
+func (s *Stream) sendEnd(errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendClosed {
+		return nil
+	}
+	s.sendClosed = true
+	close(s.sendDone)
+	h := &codec.Header{ServiceMethod: s.serviceMethod, Seq: s.seq, Flag: codec.FlagStreamEnd, Error: errMsg}
+	return s.write(h, invalidRequest)
+}
+
+// readMsg 由连接的读取协程调用，读取一条消息的 body 并放入缓存。
+// 对端遵守流量控制时缓存不会满；缓存已满时不等待，结束接收并返回 ErrStreamOverflow，由调用方通知对端，
+// 其他错误说明连接已经不可用。
+func (s *Stream) readMsg(cc codec.Codec) error {
+	m := reflect.New(s.recvType)
+	if err := cc.ReadBody(m.Interface()); err != nil {
+		return err
+	}
+
+	select {
+	case s.msgs <- m:
+	case <-s.done:
+	case <-s.ctx.Done():
+	default:
+		s.closeRecv(ErrStreamOverflow)
+		return ErrStreamOverflow
+	}
+	return nil
+}
+
+// readCredit 由连接的读取协程调用，读取 FlagStreamCredit 的 body
+func (s *Stream) readCredit(cc codec.Codec) error {
+	var n int
+	if err := cc.ReadBody(&n); err != nil {
+		return err
+	}
+	s.addCredit(n)
+	return nil
+}
+
+// closeRecv 结束接收，err 为 nil 表示对端正常结束
+func (s *Stream) closeRecv(err error) {
+	s.once.Do(func() {
+		if err == nil {
+			err = io.EOF
+		}
+		s.err = err
+		close(s.done)
+	})
+}
+
+// closeSend 标记本端不再发送，不通知对端
+func (s *Stream) closeSend() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sendClosed {
+		s.sendClosed = true
+		close(s.sendDone)
+	}
+}
+
+// isStreamFrame 是否为已打开的流中的帧，body 由所属的流读取
+func isStreamFrame(flag codec.Flag) bool {
+	return flag == codec.FlagStreamMsg || flag == codec.FlagStreamEnd || flag == codec.FlagStreamCredit
+}
+
+// streamSet 一个连接上所有未结束的流，key 为 Seq
+type streamSet struct {
+	mu sync.Mutex
+	m  map[uint64]*Stream
+}
+
+func newStreamSet() *streamSet {
+	return &streamSet{m: make(map[uint64]*Stream)}
+}
+
+func (ss *streamSet) add(s *Stream) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.m[s.seq] = s
+}
+
+func (ss *streamSet) get(seq uint64) *Stream {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.m[seq]
+}
+
+func (ss *streamSet) remove(seq uint64) *Stream {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s := ss.m[seq]
+	delete(ss.m, seq)
+	return s
+}
+
+// closeAll 连接断开时结束所有的流
+func (ss *streamSet) closeAll(err error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for seq, s := range ss.m {
+		s.closeSend()
+		s.closeRecv(err)
+		if s.cancel != nil {
+			s.cancel()
+		}
+		delete(ss.m, seq)
+	}
+}
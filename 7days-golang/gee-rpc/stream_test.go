@@ -0,0 +1,237 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Log int
+
+type TailArgs struct {
+	N    int
+	Fail bool
+}
+
+// Tail 服务端流，发送 N 行日志
+func (l Log) Tail(args TailArgs, stream *Stream) error {
+	for i := 0; i < args.N; i++ {
+		if err := stream.Send(fmt.Sprintf("line %d", i)); err != nil {
+			return err
+		}
+	}
+	if args.Fail {
+		return errors.New("tail failed")
+	}
+	return nil
+}
+
+// Chat 双向流，把收到的消息转为大写后返回
+func (l Log) Chat(ctx context.Context, prefix string, stream *Stream) error {
+	for {
+		var msg string
+		err := stream.Recv(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(prefix + strings.ToUpper(msg)); err != nil {
+			return err
+		}
+	}
+}
+
+// Wait 一直等到流被取消
+func (l Log) Wait(args int, stream *Stream) error {
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func (l Log) Echo(args string, reply *string) error {
+	*reply = args
+	return nil
+}
+
+func startStreamServer(t *testing.T) *Client {
+	server := NewServer()
+	var l Log
+	if err := server.Register(&l); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, _ := net.Listen("tcp", ":0")
+	t.Cleanup(func() { _ = lis.Close() })
+	go server.Accept(lis)
+
+	client, err := Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestStream_ServerStreaming(t *testing.T) {
+	client := startStreamServer(t)
+
+	stream, err := client.NewStream(context.Background(), "Log.Tail", &TailArgs{N: 100}, new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for {
+		var line string
+		err := stream.Recv(&line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 100 || lines[99] != "line 99" {
+		t.Fatalf("expect 100 lines, but got %d", len(lines))
+	}
+
+	// 服务端出错时，先收到已发送的消息，再收到错误
+	stream, _ = client.NewStream(context.Background(), "Log.Tail", &TailArgs{N: 1, Fail: true}, new(string))
+	var line string
+	if err := stream.Recv(&line); err != nil || line != "line 0" {
+		t.Fatalf("expect line 0, but got %s, err: %v", line, err)
+	}
+	if err := stream.Recv(&line); err == nil || err.Error() != "tail failed" {
+		t.Fatalf("expect tail failed, but got %v", err)
+	}
+}
+
+func TestStream_Bidirectional(t *testing.T) {
+	client := startStreamServer(t)
+
+	stream, err := client.NewStream(context.Background(), "Log.Chat", "> ", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"hello", "geerpc"} {
+		if err := stream.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		// 流和普通调用共用连接
+		var reply string
+		if err := client.Call(context.Background(), "Log.Echo", msg, &reply); err != nil || reply != msg {
+			t.Fatalf("expect %s, but got %s, err: %v", msg, reply, err)
+		}
+
+		var got string
+		if err := stream.Recv(&got); err != nil || got != "> "+strings.ToUpper(msg) {
+			t.Fatalf("expect > %s, but got %s, err: %v", strings.ToUpper(msg), got, err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send("late"); err != ErrStreamClosed {
+		t.Fatalf("expect ErrStreamClosed, but got %v", err)
+	}
+
+	var got string
+	if err := stream.Recv(&got); err != io.EOF {
+		t.Fatalf("expect io.EOF, but got %v", err)
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	client := startStreamServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	stream, err := client.NewStream(ctx, "Log.Wait", 1, new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	if err := stream.Recv(&got); err != context.DeadlineExceeded {
+		t.Fatalf("expect context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestStream_WrongMode(t *testing.T) {
+	client := startStreamServer(t)
+
+	var reply string
+	if err := client.Call(context.Background(), "Log.Tail", &TailArgs{N: 1}, &reply); err == nil || !strings.Contains(err.Error(), "is a stream method") {
+		t.Fatalf("expect a stream method error, but got %v", err)
+	}
+
+	stream, err := client.NewStream(context.Background(), "Log.Echo", "hi", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Recv(&reply); err == nil || !strings.Contains(err.Error(), "is not a stream method") {
+		t.Fatalf("expect not a stream method error, but got %v", err)
+	}
+}
+
+func TestStream_FlowControl(t *testing.T) {
+	client := startStreamServer(t)
+
+	// 不读取的流最多缓存 streamBufferSize 条消息，服务端的 Send 等待，不影响同一个连接上的其他调用
+	n := streamBufferSize * 4
+	stream, err := client.NewStream(context.Background(), "Log.Tail", &TailArgs{N: n}, new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if got := len(stream.msgs); got != streamBufferSize {
+		t.Fatalf("expect %d buffered lines, but got %d", streamBufferSize, got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply string
+	if err := client.Call(ctx, "Log.Echo", "hi", &reply); err != nil || reply != "hi" {
+		t.Fatalf("expect hi, but got %s, err: %v", reply, err)
+	}
+
+	// 读取后服务端继续发送，所有的消息都能收到
+	for i := 0; i < n; i++ {
+		var line string
+		if err := stream.Recv(&line); err != nil || line != fmt.Sprintf("line %d", i) {
+			t.Fatalf("expect line %d, but got %s, err: %v", i, line, err)
+		}
+	}
+	if err := stream.Recv(new(string)); err != io.EOF {
+		t.Fatalf("expect io.EOF, but got %v", err)
+	}
+}
+
+func TestStream_RecvNil(t *testing.T) {
+	client := startStreamServer(t)
+
+	stream, err := client.NewStream(context.Background(), "Log.Tail", &TailArgs{N: 1}, new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []any{nil, (*string)(nil), "line"} {
+		if err := stream.Recv(msg); err == nil || !strings.Contains(err.Error(), "non-nil *string") {
+			t.Fatalf("Recv(%#v): expect a non-nil pointer error, but got %v", msg, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package xclient
+
+import (
+	"errors"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SelectMode 负载均衡策略
+type SelectMode int
+
+const (
+	RandomSelect         SelectMode = iota // RandomSelect 随机选择
+	RoundRobinSelect                       // RoundRobinSelect 轮询
+	ConsistentHashSelect                   // ConsistentHashSelect 根据 key 做一致性哈希，同一个 key 总是选中同一个实例
+)
+
+// Discovery 服务发现的接口
+type Discovery interface {
+	Refresh() error                                  // Refresh 从注册中心更新服务列表
+	Update(servers []string) error                   // Update 手动更新服务列表
+	Get(mode SelectMode, key string) (string, error) // Get 根据负载均衡策略选择一个服务实例，key 只在一致性哈希时使用
+	GetAll() ([]string, error)                       // GetAll 返回所有的服务实例
+}
+
+var ErrNoAvailableServers = errors.New("rpc discovery: no available servers")
+
+// replicas 一致性哈希中每个真实节点对应的虚拟节点数
+const replicas = 50
+
+// MultiServersDiscovery 不需要注册中心，服务列表由用户显式提供
+type MultiServersDiscovery struct {
+	r       *rand.Rand   // r 生成随机数
+	mu      sync.RWMutex // mu 保护以下字段
+	servers []string
+	index   int               // index 记录轮询到的位置
+	ring    []uint32          // ring 一致性哈希环，已排序的虚拟节点哈希值
+	hashMap map[uint32]string // hashMap 虚拟节点哈希值到真实节点的映射
+}
+
+var _ Discovery = (*MultiServersDiscovery)(nil)
+
+// NewMultiServerDiscovery 创建 MultiServersDiscovery
+func NewMultiServerDiscovery(servers []string) *MultiServersDiscovery {
+	d := &MultiServersDiscovery{
+		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	d.setServers(servers)
+	// index 随机初始化，避免每次都从 0 开始
+	d.index = d.r.Intn(math.MaxInt32 - 1)
+	return d
+}
+
+// setServers 更新服务列表并重建哈希环，调用方需要持有写锁。
+// 保存 servers 的拷贝，调用方之后修改 servers 不会影响服务列表
+func (d *MultiServersDiscovery) setServers(servers []string) {
+	d.servers = append([]string(nil), servers...)
+	d.ring = make([]uint32, 0, len(servers)*replicas)
+	d.hashMap = make(map[uint32]string, len(servers)*replicas)
+
+	for _, server := range servers {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + server))
+			d.ring = append(d.ring, hash)
+			d.hashMap[hash] = server
+		}
+	}
+	sort.Slice(d.ring, func(i, j int) bool { return d.ring[i] < d.ring[j] })
+}
+
+// Refresh 对 MultiServersDiscovery 没有意义，直接忽略
+func (d *MultiServersDiscovery) Refresh() error {
+	return nil
+}
+
+// Update 动态更新服务列表，servers 会被复制
+func (d *MultiServersDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	return nil
+}
+
+// Get 根据负载均衡策略选择一个服务实例
+func (d *MultiServersDiscovery) Get(mode SelectMode, key string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := len(d.servers)
+	if n == 0 {
+		return "", ErrNoAvailableServers
+	}
+
+	switch mode {
+	case RandomSelect:
+		return d.servers[d.r.Intn(n)], nil
+	case RoundRobinSelect:
+		// 服务列表可能被更新，所以需要取模
+		s := d.servers[d.index%n]
+		d.index = (d.index + 1) % n
+		return s, nil
+	case ConsistentHashSelect:
+		hash := crc32.ChecksumIEEE([]byte(key))
+		// 顺时针找到第一个虚拟节点，找不到说明在环的末尾，取第一个
+		idx := sort.Search(len(d.ring), func(i int) bool { return d.ring[i] >= hash })
+		return d.hashMap[d.ring[idx%len(d.ring)]], nil
+	default:
+		return "", errors.New("rpc discovery: not supported select mode")
+	}
+}
+
+// GetAll 返回所有的服务实例
+func (d *MultiServersDiscovery) GetAll() ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	// 返回一份拷贝
+	servers := make([]string, len(d.servers))
+	copy(servers, d.servers)
+	return servers, nil
+}
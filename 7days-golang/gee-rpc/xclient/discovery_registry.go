@@ -0,0 +1,159 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package xclient
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryDiscovery 基于注册中心的服务发现，服务列表过期后从注册中心重新获取。
+// 已经获取过服务列表时，过期后在后台刷新，刷新期间和刷新失败时继续使用旧的列表；
+// 也可以通过 StartRefresh 定期刷新，使调用方不必等待过期。
+type RegistryDiscovery struct {
+	*MultiServersDiscovery
+	registry   string        // registry 注册中心的地址
+	timeout    time.Duration // timeout 服务列表的过期时间
+	lastUpdate time.Time     // lastUpdate 最后一次从注册中心更新的时间，由 mu 保护
+	refreshing bool          // refreshing 是否有后台刷新在进行，由 mu 保护
+}
+
+var _ Discovery = (*RegistryDiscovery)(nil)
+
+// defaultUpdateTimeout 默认的服务列表过期时间
+const defaultUpdateTimeout = time.Second * 10
+
+// NewRegistryDiscovery 创建 RegistryDiscovery，timeout 为 0 时使用默认值
+func NewRegistryDiscovery(registerAddr string, timeout time.Duration) *RegistryDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+
+	return &RegistryDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		registry:              registerAddr,
+		timeout:               timeout,
+	}
+}
+
+// Update 手动更新服务列表
+func (d *RegistryDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// Refresh 服务列表过期后从注册中心重新获取，等待获取完成
+func (d *RegistryDiscovery) Refresh() error {
+	d.mu.RLock()
+	fresh := d.lastUpdate.Add(d.timeout).After(time.Now())
+	d.mu.RUnlock()
+
+	if fresh {
+		return nil
+	}
+	return d.fetch()
+}
+
+// StartRefresh 每隔 interval 从注册中心获取一次服务列表，interval 为 0 时使用过期时间。
+// 获取失败时保留旧的列表，返回的 stop 用于停止刷新。
+func (d *RegistryDiscovery) StartRefresh(interval time.Duration) (stop func()) {
+	if interval == 0 {
+		interval = d.timeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				_ = d.fetch()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// fetch 从注册中心获取服务列表，请求时不持有锁，失败时不修改当前的列表
+func (d *RegistryDiscovery) fetch() error {
+	log.Println("rpc registry: refresh servers from registry", d.registry)
+	httpClient := &http.Client{Timeout: time.Second * 10}
+	resp, err := httpClient.Get(d.registry)
+	if err != nil {
+		log.Println("rpc registry refresh err:", err)
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("rpc registry: unexpected status %s", resp.Status)
+		log.Println("rpc registry refresh err:", err)
+		return err
+	}
+
+	servers := make([]string, 0)
+	for _, server := range strings.Split(resp.Header.Get("X-Geerpc-Servers"), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// ensure 确保有可用的服务列表：从未获取过时等待获取，过期时在后台刷新并继续使用旧的列表
+func (d *RegistryDiscovery) ensure() error {
+	d.mu.Lock()
+	if d.lastUpdate.IsZero() {
+		d.mu.Unlock()
+		return d.fetch()
+	}
+
+	if !d.refreshing && !d.lastUpdate.Add(d.timeout).After(time.Now()) {
+		d.refreshing = true
+		go func() {
+			_ = d.fetch()
+			d.mu.Lock()
+			d.refreshing = false
+			d.mu.Unlock()
+		}()
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// Get 先确保有可用的服务列表，再根据负载均衡策略选择一个实例
+func (d *RegistryDiscovery) Get(mode SelectMode, key string) (string, error) {
+	if err := d.ensure(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode, key)
+}
+
+// GetAll 先确保有可用的服务列表，再返回所有实例
+func (d *RegistryDiscovery) GetAll() ([]string, error) {
+	if err := d.ensure(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package xclient
+
+import (
+	"context"
+	"geerpc"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// XClient 支持负载均衡的客户端，为每个服务实例缓存一个 Client
+type XClient struct {
+	d       Discovery                 // d 服务发现
+	mode    SelectMode                // mode 负载均衡策略
+	opt     *geerpc.Option            // opt 创建 Client 使用的 Option
+	mu      sync.Mutex                // mu 保护 clients
+	clients map[string]*geerpc.Client // clients key 为 rpcAddr，复用已经创建的 Client
+
+	interceptors []geerpc.UnaryClientInterceptor // interceptors 添加到每个 Client 上的拦截器
+
+	// Retries 连接失败时换一个实例重试的次数
+	Retries int
+}
+
+var (
+	_ io.Closer     = (*XClient)(nil)
+	_ geerpc.Caller = (*XClient)(nil)
+)
+
+// NewXClient 创建 XClient，默认连接失败时重试 2 次
+func NewXClient(d Discovery, mode SelectMode, opt *geerpc.Option) *XClient {
+	return &XClient{
+		d:       d,
+		mode:    mode,
+		opt:     opt,
+		clients: make(map[string]*geerpc.Client),
+		Retries: 2,
+	}
+}
+
+// Use 添加客户端拦截器，对之后创建的 Client 生效。需要在发起调用前调用。
+func (xc *XClient) Use(interceptors ...geerpc.UnaryClientInterceptor) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.interceptors = append(xc.interceptors, interceptors...)
+}
+
+// Close 关闭所有缓存的 Client
+func (xc *XClient) Close() error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+
+	for key, client := range xc.clients {
+		// 忽略错误，能关的都关掉
+		_ = client.Close()
+		delete(xc.clients, key)
+	}
+	return nil
+}
+
+// dial 返回 rpcAddr 对应的 Client，缓存中的 Client 不可用时重新创建。
+// 建立连接时不持有锁，一个实例连接缓慢不会阻塞对其他实例的调用；
+// 多个协程同时建立连接时只保留先放入缓存的 Client。
+func (xc *XClient) dial(rpcAddr string) (*geerpc.Client, error) {
+	if client := xc.cached(rpcAddr); client != nil {
+		return client, nil
+	}
+
+	client, err := geerpc.XDial(rpcAddr, xc.opt)
+	if err != nil {
+		return nil, err
+	}
+
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if cached, ok := xc.clients[rpcAddr]; ok && cached.IsAvailable() {
+		_ = client.Close()
+		return cached, nil
+	}
+	client.Use(xc.interceptors...)
+	xc.clients[rpcAddr] = client
+	return client, nil
+}
+
+// cached 返回缓存中可用的 Client，不可用的 Client 会被关闭并移出缓存
+func (xc *XClient) cached(rpcAddr string) *geerpc.Client {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+
+	client, ok := xc.clients[rpcAddr]
+	if !ok {
+		return nil
+	}
+	if !client.IsAvailable() {
+		_ = client.Close()
+		delete(xc.clients, rpcAddr)
+		return nil
+	}
+	return client
+}
+
+// call 调用 rpcAddr 上的方法
+// 返回的 retry 表示错误是否由连接引起，这种情况可以换一个实例重试
+func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply any) (retry bool, err error) {
+	client, err := xc.dial(rpcAddr)
+	if err != nil {
+		return true, err
+	}
+
+	err = client.Call(ctx, serviceMethod, args, reply)
+	// 服务端返回的错误不重试，连接断开则重试
+	return err != nil && !client.IsAvailable() && ctx.Err() == nil, err
+}
+
+// Call 根据负载均衡策略选择一个实例并调用方法，连接失败时按 Retries 重试。
+// 一致性哈希使用 serviceMethod 作为 key，需要自定义 key 时使用 CallWithKey。
+func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+	return xc.CallWithKey(ctx, serviceMethod, serviceMethod, args, reply)
+}
+
+// CallWithKey 同 Call，key 用于一致性哈希选择实例，例如用户 ID
+func (xc *XClient) CallWithKey(ctx context.Context, key, serviceMethod string, args, reply any) error {
+	var err error
+	for i := 0; i <= xc.Retries; i++ {
+		var rpcAddr string
+		rpcAddr, err = xc.d.Get(xc.mode, key)
+		if err != nil {
+			return err
+		}
+
+		var retry bool
+		if retry, err = xc.call(rpcAddr, ctx, serviceMethod, args, reply); !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// Broadcast 并发调用所有实例上的方法。
+// 任意一个实例出错则返回第一个错误，并取消其他调用；全部成功时 reply 为第一个成功的结果。
+func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply any) error {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return ErrNoAvailableServers
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex // mu 保护 e 和 replyDone
+	var e error
+	replyDone := reply == nil // reply 为 nil 时不需要设置值
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+
+			// 每个调用使用独立的 reply，避免并发写
+			var clonedReply any
+			if reply != nil {
+				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+			}
+
+			_, err := xc.call(rpcAddr, ctx, serviceMethod, args, clonedReply)
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil && e == nil {
+				e = err
+				cancel()
+			}
+
+			if err == nil && !replyDone {
+				reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(clonedReply).Elem())
+				replyDone = true
+			}
+		}(rpcAddr)
+	}
+
+	wg.Wait()
+	return e
+}
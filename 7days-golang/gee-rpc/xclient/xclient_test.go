@@ -0,0 +1,271 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package xclient
+
+import (
+	"context"
+	"geerpc"
+	"geerpc/registry"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type Foo int
+
+type Args struct{ Num1, Num2 int }
+
+func (f Foo) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+// startServer 开启一个注册了 Foo 的服务，返回 rpcAddr
+func startServer(t *testing.T) (string, net.Listener) {
+	server := geerpc.NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	return "tcp@" + l.Addr().String(), l
+}
+
+func TestMultiServersDiscovery_Get(t *testing.T) {
+	servers := []string{"tcp@a", "tcp@b", "tcp@c"}
+	d := NewMultiServerDiscovery(servers)
+
+	first, _ := d.Get(RoundRobinSelect, "")
+	second, _ := d.Get(RoundRobinSelect, "")
+	if first == second {
+		t.Fatal("round robin should select different servers")
+	}
+
+	s1, _ := d.Get(ConsistentHashSelect, "user-1")
+	for i := 0; i < 10; i++ {
+		if s, _ := d.Get(ConsistentHashSelect, "user-1"); s != s1 {
+			t.Fatal("consistent hash should always select the same server for the same key")
+		}
+	}
+
+	// Update 保存拷贝，之后修改传入的切片不影响服务列表
+	updated := []string{"tcp@d"}
+	_ = d.Update(updated)
+	updated[0] = "tcp@e"
+	if all, _ := d.GetAll(); len(all) != 1 || all[0] != "tcp@d" {
+		t.Fatalf("expect [tcp@d], but got %v", all)
+	}
+
+	_ = d.Update(nil)
+	if _, err := d.Get(RandomSelect, ""); err != ErrNoAvailableServers {
+		t.Fatal("expect ErrNoAvailableServers")
+	}
+}
+
+func TestXClient_Call(t *testing.T) {
+	addr1, l1 := startServer(t)
+	defer func() { _ = l1.Close() }()
+	addr2, l2 := startServer(t)
+	defer func() { _ = l2.Close() }()
+
+	for _, mode := range []SelectMode{RandomSelect, RoundRobinSelect, ConsistentHashSelect} {
+		xc := NewXClient(NewMultiServerDiscovery([]string{addr1, addr2}), mode, nil)
+		for i := 0; i < 5; i++ {
+			var reply int
+			if err := xc.Call(context.Background(), "Foo.Sum", &Args{Num1: i, Num2: i}, &reply); err != nil || reply != i+i {
+				t.Fatalf("mode %d: expect %d, but got %d, err: %v", mode, i+i, reply, err)
+			}
+		}
+		_ = xc.Close()
+	}
+}
+
+func TestXClient_Retry(t *testing.T) {
+	addr, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	// 一个不可用的实例，连接失败后应重试到可用的实例上
+	xc := NewXClient(NewMultiServerDiscovery([]string{"tcp@127.0.0.1:1", addr}), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	for i := 0; i < 4; i++ {
+		var reply int
+		if err := xc.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+		}
+	}
+}
+
+func TestXClient_SlowDial(t *testing.T) {
+	addr, l := startServer(t)
+	defer func() { _ = l.Close() }()
+
+	// 只接受连接不完成握手的实例，连接会一直等到 ConnectTimeout
+	slow, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = slow.Close() }()
+	go func() {
+		for {
+			conn, err := slow.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+		}
+	}()
+
+	xc := NewXClient(NewMultiServerDiscovery(nil), RandomSelect, &geerpc.Option{ConnectTimeout: time.Second})
+	defer func() { _ = xc.Close() }()
+
+	dialing := make(chan struct{})
+	go func() {
+		close(dialing)
+		_, _ = xc.call("tcp@"+slow.Addr().String(), context.Background(), "Foo.Sum", &Args{}, new(int))
+	}()
+	<-dialing
+	time.Sleep(time.Millisecond * 100)
+
+	start := time.Now()
+	var reply int
+	if _, err := xc.call(addr, context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Fatalf("a slow dial shouldn't block calls to other servers, took %s", d)
+	}
+}
+
+func TestXClient_Broadcast(t *testing.T) {
+	addr1, l1 := startServer(t)
+	defer func() { _ = l1.Close() }()
+	addr2, l2 := startServer(t)
+	defer func() { _ = l2.Close() }()
+
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr1, addr2}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var reply int
+	if err := xc.Broadcast(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+
+	if err := xc.Broadcast(context.Background(), "Foo.Mul", &Args{Num1: 1, Num2: 2}, &reply); err == nil {
+		t.Fatal("expect an error when method not found")
+	}
+}
+
+func TestRegistryDiscovery(t *testing.T) {
+	ts := httptest.NewServer(registry.New(0))
+	defer ts.Close()
+
+	addr1, l1 := startServer(t)
+	defer func() { _ = l1.Close() }()
+	addr2, l2 := startServer(t)
+	defer func() { _ = l2.Close() }()
+
+	stop1 := registry.Heartbeat(ts.URL, addr1, time.Minute)
+	defer stop1()
+	stop2 := registry.Heartbeat(ts.URL, addr2, time.Minute)
+	defer stop2()
+
+	d := NewRegistryDiscovery(ts.URL, time.Millisecond*100)
+	servers, err := d.GetAll()
+	if err != nil || len(servers) != 2 {
+		t.Fatalf("expect 2 servers, but got %v, err: %v", servers, err)
+	}
+
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var reply int
+	if err := xc.Broadcast(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, but got %d, err: %v", reply, err)
+	}
+}
+
+func TestRegistryDiscovery_Refresh(t *testing.T) {
+	ts := httptest.NewServer(registry.New(0))
+
+	stop1 := registry.Heartbeat(ts.URL, "tcp@a", time.Minute)
+	defer stop1()
+
+	d := NewRegistryDiscovery(ts.URL, time.Millisecond*50)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 {
+		t.Fatalf("expect 1 server, but got %v, err: %v", servers, err)
+	}
+
+	// 定期刷新，不需要等调用方触发
+	stop := d.StartRefresh(time.Millisecond * 20)
+	stop2 := registry.Heartbeat(ts.URL, "tcp@b", time.Minute)
+	defer stop2()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if servers, _ := d.MultiServersDiscovery.GetAll(); len(servers) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect the new server to be discovered by the periodic refresh")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	stop()
+
+	// 注册中心不可用时继续使用过期的列表
+	ts.Close()
+	time.Sleep(time.Millisecond * 100)
+	for i := 0; i < 3; i++ {
+		if server, err := d.Get(RoundRobinSelect, ""); err != nil || server == "" {
+			t.Fatalf("expect the stale server list, but got %q, err: %v", server, err)
+		}
+	}
+	if err := d.Refresh(); err == nil {
+		t.Fatal("expect Refresh to report the registry error")
+	}
+}
+
+func TestRegistryDiscovery_RefreshStatus(t *testing.T) {
+	var fail int32
+	reg := registry.New(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" && atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reg.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	stop := registry.Heartbeat(ts.URL, "tcp@a", time.Minute)
+	defer stop()
+
+	d := NewRegistryDiscovery(ts.URL, time.Millisecond*10)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 {
+		t.Fatalf("expect 1 server, but got %v, err: %v", servers, err)
+	}
+
+	// 注册中心返回错误的状态码时不修改列表，也不更新 lastUpdate
+	atomic.StoreInt32(&fail, 1)
+	time.Sleep(time.Millisecond * 20)
+	if err := d.Refresh(); err == nil {
+		t.Fatal("expect Refresh to report the status error")
+	}
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 {
+		t.Fatalf("expect the stale server list, but got %v, err: %v", servers, err)
+	}
+	d.mu.RLock()
+	stale := !d.lastUpdate.Add(d.timeout).After(time.Now())
+	d.mu.RUnlock()
+	if !stale {
+		t.Fatal("expect lastUpdate unchanged after a failed refresh")
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// binding 把请求中的参数绑定到结构体并校验
+
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMultipartMemory 解析 multipart 表单时最多使用的内存，超过的部分保存到临时文件
+const defaultMultipartMemory = 32 << 20
+
+// FieldError 一个字段的错误，可以直接通过 c.JSON 返回给客户端
+type FieldError struct {
+	Field   string `json:"field"`           // Field 字段在请求中的名称，即 json、form 或 uri tag 中的名称
+	Tag     string `json:"tag"`             // Tag 没有通过的规则，例如：required、min，类型转换失败时为 type
+	Param   string `json:"param,omitempty"` // Param 规则的参数，例如：min=1 中的 1
+	Message string `json:"message"`         // Message 可读的错误信息
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors 绑定或校验失败的所有字段
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Bind 根据请求方法和 Content-Type 选择绑定方式：
+// JSON 请求使用 ShouldBindJSON，GET 请求使用 ShouldBindQuery，其他使用 ShouldBindForm。
+// 失败时返回 400，body 为 {"message": ..., "errors": [...]}，并且不再执行之后的 HandlerFunc；
+// binding tag 不合法时返回 500。
+func (c *Context) Bind(obj any) error {
+	var err error
+	switch {
+	case isJSON(c.Req.Header.Get("Content-Type")):
+		err = c.ShouldBindJSON(obj)
+	case c.Method == http.MethodGet:
+		err = c.ShouldBindQuery(obj)
+	default:
+		err = c.ShouldBindForm(obj)
+	}
+
+	if errors.Is(err, ErrInvalidBindingRule) {
+		log.Println(err)
+		c.Fail(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return err
+	}
+	if err != nil {
+		c.index = len(c.handlers)
+		body := H{"message": err.Error()}
+		var verrs ValidationErrors
+		if errors.As(err, &verrs) {
+			body["errors"] = verrs
+		}
+		c.JSON(http.StatusBadRequest, body)
+	}
+	return err
+}
+
+// ShouldBindJSON 从 JSON body 绑定，字段名称使用 json tag
+func (c *Context) ShouldBindJSON(obj any) error {
+	if err := checkRules(obj); err != nil {
+		return err
+	}
+	if c.Req.Body == nil {
+		return errors.New("gee: empty request body")
+	}
+	if err := json.NewDecoder(c.Req.Body).Decode(obj); err != nil {
+		return fmt.Errorf("gee: invalid json body: %w", err)
+	}
+	return validate(obj, "json")
+}
+
+// ShouldBindQuery 从 URL 中的查询参数绑定，字段名称使用 form tag
+func (c *Context) ShouldBindQuery(obj any) error {
+	return bindValues(obj, c.Req.URL.Query(), "form")
+}
+
+// ShouldBindForm 从表单绑定，和 PostForm 一样 body 中的参数优先于查询参数，字段名称使用 form tag
+func (c *Context) ShouldBindForm(obj any) error {
+	var err error
+	if ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type")); ct == "multipart/form-data" {
+		err = c.Req.ParseMultipartForm(defaultMultipartMemory)
+	} else {
+		err = c.Req.ParseForm()
+	}
+	if err != nil {
+		return fmt.Errorf("gee: invalid form: %w", err)
+	}
+	return bindValues(obj, c.Req.Form, "form")
+}
+
+// ShouldBindURI 从路由参数绑定，例如：/users/:id，字段名称使用 uri tag
+func (c *Context) ShouldBindURI(obj any) error {
+	values := make(map[string][]string, len(c.Params))
+	for k, v := range c.Params {
+		values[k] = []string{v}
+	}
+	return bindValues(obj, values, "uri")
+}
+
+// isJSON Content-Type 是否为 JSON
+func isJSON(contentType string) bool {
+	ct, _, _ := mime.ParseMediaType(contentType)
+	return ct == "application/json"
+}
+
+// bindValues 把 values 绑定到 obj 并校验，obj 必须是指向结构体的指针
+func bindValues(obj any, values map[string][]string, tag string) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: bind %T, expect a pointer to struct", obj)
+	}
+	if err := checkRules(obj); err != nil {
+		return err
+	}
+
+	var errs ValidationErrors
+	mapValues(v.Elem(), values, tag, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return validate(obj, tag)
+}
+
+// mapValues 按 tag 中的名称把 values 写入结构体的字段，没有 tag 时使用字段名，tag 为 - 时跳过。
+// 没有 tag 的嵌套结构体会展开，类型转换失败的字段记录到 errs。
+func mapValues(v reflect.Value, values map[string][]string, tag string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, tagged := fieldName(sf, tag)
+		if name == "-" {
+			continue
+		}
+
+		field := v.Field(i)
+		if !tagged && sf.Type.Kind() == reflect.Struct {
+			mapValues(field, values, tag, errs)
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setField(field, vs); err != nil {
+			*errs = append(*errs, &FieldError{
+				Field:   name,
+				Tag:     "type",
+				Param:   sf.Type.String(),
+				Message: fmt.Sprintf("%s must be a valid %s", name, sf.Type),
+			})
+		}
+	}
+}
+
+// fieldName 返回字段在 tag 中的名称，tagged 表示是否设置了 tag
+func fieldName(sf reflect.StructField, tag string) (name string, tagged bool) {
+	name = strings.Split(sf.Tag.Get(tag), ",")[0]
+	if name == "" {
+		return sf.Name, false
+	}
+	return name, true
+}
+
+// setField 把字符串转换为字段的类型后写入，支持基本类型、指针和切片
+func setField(field reflect.Value, vs []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), vs); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	default:
+		return setValue(field, vs[0])
+	}
+}
+
+// setValue 把单个字符串转换为基本类型后写入
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("gee: unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// ErrInvalidBindingRule binding tag 写错了，例如未知的规则、参数不合法或者规则不适用于字段的类型。
+// 属于程序的错误，第一次绑定某个类型时就会返回，Bind 返回 500。
+var ErrInvalidBindingRule = errors.New("gee: invalid binding rule")
+
+// rule 解析后的一条校验规则
+type rule struct {
+	tag   string  // tag 规则的名称，例如：required、min
+	param string  // param 规则的参数，例如：min=1 中的 1
+	limit float64 // limit min、max 的参数
+}
+
+// fieldRules 一个字段的校验规则
+type fieldRules struct {
+	index  int          // index 字段在结构体中的下标
+	rules  []rule       // rules binding tag 中的规则
+	nested *structRules // nested 字段为结构体或指向结构体的指针时，结构体的规则
+}
+
+// structRules 一个结构体类型的校验规则，只包含需要校验的字段
+type structRules struct {
+	fields []fieldRules
+}
+
+// ruleCache 缓存每个结构体类型解析后的规则，值为 *structRules 或 error
+var ruleCache sync.Map
+
+// rulesOf 返回结构体类型 t 的校验规则，第一次使用时解析并检查所有的 binding tag，包括嵌套的结构体
+func rulesOf(t reflect.Type) (*structRules, error) {
+	if v, ok := ruleCache.Load(t); ok {
+		if err, isErr := v.(error); isErr {
+			return nil, err
+		}
+		return v.(*structRules), nil
+	}
+
+	sr, err := parseRules(t, make(map[reflect.Type]*structRules))
+	if err != nil {
+		ruleCache.Store(t, err)
+		return nil, err
+	}
+	ruleCache.Store(t, sr)
+	return sr, nil
+}
+
+// parseRules 解析结构体类型 t 的规则，parsing 记录正在解析的类型，用于处理递归的结构体
+func parseRules(t reflect.Type, parsing map[reflect.Type]*structRules) (*structRules, error) {
+	if sr, ok := parsing[t]; ok {
+		return sr, nil
+	}
+	sr := new(structRules)
+	parsing[t] = sr
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fr := fieldRules{index: i}
+
+		if tags := sf.Tag.Get("binding"); tags != "" {
+			for _, r := range strings.Split(tags, ",") {
+				parsed, err := parseRule(sf.Type, r)
+				if err != nil {
+					return nil, fmt.Errorf("%w %q of %s.%s: %v", ErrInvalidBindingRule, r, t, sf.Name, err)
+				}
+				fr.rules = append(fr.rules, parsed)
+			}
+		}
+
+		// 嵌套的结构体同样需要校验
+		if ft := indirectType(sf.Type); ft.Kind() == reflect.Struct {
+			nested, err := parseRules(ft, parsing)
+			if err != nil {
+				return nil, err
+			}
+			fr.nested = nested
+		}
+
+		if len(fr.rules) > 0 || fr.nested != nil {
+			sr.fields = append(sr.fields, fr)
+		}
+	}
+	return sr, nil
+}
+
+// parseRule 解析一条规则，并检查是否适用于字段的类型 ft
+func parseRule(ft reflect.Type, s string) (rule, error) {
+	tag, param, _ := strings.Cut(s, "=")
+	r := rule{tag: tag, param: param}
+
+	switch tag {
+	case "required", "omitempty":
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return r, fmt.Errorf("%s requires a number", tag)
+		}
+		if !sizable(indirectType(ft)) {
+			return r, fmt.Errorf("%s does not apply to %s", tag, ft)
+		}
+		r.limit = limit
+	case "email":
+		if indirectType(ft).Kind() != reflect.String {
+			return r, fmt.Errorf("email requires a string field, got %s", ft)
+		}
+	default:
+		return r, errors.New("unknown rule")
+	}
+	return r, nil
+}
+
+// checkRules 绑定前检查 obj 的 binding tag，使不合法的 tag 在第一次绑定时就返回错误，而不是取决于请求的内容
+func checkRules(obj any) error {
+	t := reflect.TypeOf(obj)
+	if t == nil {
+		return nil
+	}
+	if t = indirectType(t); t.Kind() != reflect.Struct {
+		return nil
+	}
+	_, err := rulesOf(t)
+	return err
+}
+
+// indirectType 返回指针指向的类型
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// validate 按 binding tag 校验结构体，返回所有没有通过的字段，tag 用于确定错误中的字段名称。
+// 支持的规则：
+//   - required 不能为零值
+//   - omitempty 为零值时跳过其他规则
+//   - min=n、max=n 数字的大小，字符串、切片、数组和 map 的长度
+//   - email 合法的邮箱地址，只能用于字符串
+//
+// binding tag 不合法时返回 ErrInvalidBindingRule。
+func validate(obj any, tag string) error {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	sr, err := rulesOf(v.Type())
+	if err != nil {
+		return err
+	}
+
+	var errs ValidationErrors
+	validateStruct(v, sr, tag, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, sr *structRules, tag string, errs *ValidationErrors) {
+	t := v.Type()
+	for _, fr := range sr.fields {
+		name, _ := fieldName(t.Field(fr.index), tag)
+		field := v.Field(fr.index)
+
+		for _, r := range fr.rules {
+			if r.tag == "omitempty" {
+				if field.IsZero() {
+					break
+				}
+				continue
+			}
+			// 没有传入的指针字段只检查 required
+			if r.tag != "required" && field.Kind() == reflect.Ptr && field.IsNil() {
+				continue
+			}
+			if fe := checkRule(field, name, r); fe != nil {
+				*errs = append(*errs, fe)
+				break
+			}
+		}
+
+		if fr.nested == nil {
+			continue
+		}
+		if field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Struct {
+			validateStruct(field, fr.nested, tag, errs)
+		}
+	}
+}
+
+// checkRule 校验一条规则，规则已经在 parseRule 中检查过
+func checkRule(field reflect.Value, name string, r rule) *FieldError {
+	fe := &FieldError{Field: name, Tag: r.tag, Param: r.param}
+
+	switch r.tag {
+	case "required":
+		if field.IsZero() {
+			fe.Message = name + " is required"
+			return fe
+		}
+	case "min", "max":
+		n, isLen, ok := size(field)
+		if !ok || r.tag == "min" && n < r.limit || r.tag == "max" && n > r.limit {
+			subject, bound := name, "least"
+			if isLen {
+				subject += " length"
+			}
+			if r.tag == "max" {
+				bound = "most"
+			}
+			fe.Message = fmt.Sprintf("%s must be at %s %s", subject, bound, r.param)
+			return fe
+		}
+	case "email":
+		s := reflect.Indirect(field).String()
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			fe.Message = name + " must be a valid email address"
+			return fe
+		}
+	}
+	return nil
+}
+
+// sizable min、max 是否适用于类型 t
+func sizable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// size 返回数字的值，或者字符串、切片、数组和 map 的长度，isLen 表示是否为长度。
+// 其他类型没有大小，ok 为 false
+func size(v reflect.Value) (n float64, isLen bool, ok bool) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,213 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Page struct {
+	Page int `form:"page" binding:"omitempty,min=1"`
+	Size int `form:"size" binding:"omitempty,max=100"`
+}
+
+type SignUp struct {
+	Name   string   `json:"name" form:"name" binding:"required,min=2,max=10"`
+	Email  string   `json:"email" form:"email" binding:"required,email"`
+	Age    *uint8   `json:"age" form:"age" binding:"min=18"`
+	Tags   []string `json:"tags" form:"tag" binding:"max=2"`
+	Admin  bool     `json:"-" form:"admin"`
+	Ignore string   `json:"-" form:"-"`
+	Page
+}
+
+type UserURI struct {
+	ID   int64  `uri:"id" binding:"required,min=1"`
+	Lang string `uri:"lang"`
+}
+
+// testContext 创建一个不属于 engine 的 Context，直接调用绑定方法
+func testContext(w http.ResponseWriter, req *http.Request) *Context {
+	c := &Context{}
+	c.reset(w, req)
+	return c
+}
+
+func TestShouldBindQuery(t *testing.T) {
+	c := testContext(httptest.NewRecorder(), httptest.NewRequest("GET",
+		"/?name=gee&email=gee@example.com&age=20&tag=a&tag=b&admin=true&Ignore=x&page=2&size=10", nil))
+
+	var s SignUp
+	if err := c.ShouldBindQuery(&s); err != nil {
+		t.Fatal(err)
+	}
+	age := uint8(20)
+	want := SignUp{Name: "gee", Email: "gee@example.com", Age: &age, Tags: []string{"a", "b"}, Admin: true, Page: Page{2, 10}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("expect %+v, but got %+v", want, s)
+	}
+}
+
+func TestShouldBindForm(t *testing.T) {
+	form := url.Values{"name": {"g"}, "email": {"gee"}, "age": {"300"}, "page": {"0"}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := testContext(httptest.NewRecorder(), req)
+
+	// 类型转换失败时先返回转换错误
+	var s SignUp
+	err := c.ShouldBindForm(&s)
+	verrs, ok := err.(ValidationErrors)
+	if !ok || len(verrs) != 1 || verrs[0].Field != "age" || verrs[0].Tag != "type" {
+		t.Fatalf("expect age type error, but got %v", err)
+	}
+
+	form.Set("age", "17")
+	form.Add("tag", "a")
+	form.Add("tag", "b")
+	form.Add("tag", "c")
+	req = httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c = testContext(httptest.NewRecorder(), req)
+
+	err = c.ShouldBindForm(&s)
+	verrs, _ = err.(ValidationErrors)
+	var got []string
+	for _, fe := range verrs {
+		got = append(got, fe.Field+":"+fe.Tag)
+	}
+	if want := "name:min email:email age:min tag:max"; strings.Join(got, " ") != want {
+		t.Fatalf("expect %s, but got %v", want, err)
+	}
+}
+
+func TestShouldBindURI(t *testing.T) {
+	c := testContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42/go", nil))
+	c.Params = map[string]string{"id": "42", "lang": "go"}
+
+	var u UserURI
+	if err := c.ShouldBindURI(&u); err != nil || u.ID != 42 || u.Lang != "go" {
+		t.Fatalf("expect {42 go}, but got %+v, err: %v", u, err)
+	}
+
+	c.Params = map[string]string{"id": "-1"}
+	if err := c.ShouldBindURI(&u); err == nil || err.Error() != "id must be at least 1" {
+		t.Fatalf("expect id must be at least 1, but got %v", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	r := New()
+	r.POST("/signup", func(c *Context) {
+		var s SignUp
+		if c.Bind(&s) != nil {
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}, func(c *Context) {
+		c.String(http.StatusOK, "after")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"name":"gee","email":"gee@example.com","tags":["a"]}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"gee"`) {
+		t.Fatalf("expect 200, but got %d %s", w.Code, w.Body.String())
+	}
+
+	// 校验失败时返回 400 和字段错误，并且不再执行之后的 HandlerFunc
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/signup", strings.NewReader(`{"email":"gee@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || len(body.Errors) != 1 || body.Errors[0].Field != "name" || body.Message != "name is required" {
+		t.Fatalf("expect 400 name is required, but got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/signup", strings.NewReader(`{"name":`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid json body") {
+		t.Fatalf("expect 400 invalid json body, but got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Node 递归的结构体，解析规则时不能无限递归
+type Node struct {
+	Name string `form:"name" binding:"max=3"`
+	Next *Node  `form:"-"`
+}
+
+func TestInvalidRule(t *testing.T) {
+	type Inner struct {
+		Name string `binding:"unknown"`
+	}
+
+	// 不合法的 binding tag 在第一次绑定时返回 ErrInvalidBindingRule，和请求的内容无关
+	for _, obj := range []any{
+		&struct {
+			Name string `form:"name" binding:"unknown"`
+		}{},
+		&struct {
+			Age int `form:"age" binding:"min=abc"`
+		}{},
+		&struct {
+			Age int `form:"age" binding:"email"`
+		}{},
+		&struct {
+			Admin bool `form:"admin" binding:"min=1"`
+		}{},
+		&struct{ Inner *Inner }{},
+	} {
+		c := testContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if err := c.ShouldBindQuery(obj); !errors.Is(err, ErrInvalidBindingRule) {
+			t.Fatalf("%T: expect ErrInvalidBindingRule, got %v", obj, err)
+		}
+		if err := validate(obj, "form"); !errors.Is(err, ErrInvalidBindingRule) {
+			t.Fatalf("%T: expect ErrInvalidBindingRule from validate, got %v", obj, err)
+		}
+	}
+
+	// Bind 时属于服务端的错误，返回 500
+	w := httptest.NewRecorder()
+	c := testContext(w, httptest.NewRequest("GET", "/?name=gee", nil))
+	var bad struct {
+		Name string `form:"name" binding:"unknown"`
+	}
+	if err := c.Bind(&bad); !errors.Is(err, ErrInvalidBindingRule) || w.Code != http.StatusInternalServerError {
+		t.Fatalf("expect 500, got %d, err: %v", w.Code, err)
+	}
+
+	node := &Node{Name: "gee", Next: &Node{Name: "geektutu"}}
+	if err := validate(node, "form"); err == nil || !strings.Contains(err.Error(), "name length must be at most 3") {
+		t.Fatalf("expect nested max error, got %v", err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	if _, _, ok := size(reflect.ValueOf(true)); ok {
+		t.Fatal("bool should not have a size")
+	}
+	if n, isLen, ok := size(reflect.ValueOf("极客")); !ok || !isLen || n != 2 {
+		t.Fatalf("expect length 2, got %v %v %v", n, isLen, ok)
+	}
+}
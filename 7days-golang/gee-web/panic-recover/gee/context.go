@@ -5,6 +5,7 @@
 package gee
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,18 +17,20 @@ type H map[string]interface{}
 // Context 目前只包含了 http.ResponseWriter 和 *http.Request，另外提供了对 Method 和 Path 这两个常用属性的直接访问。
 // 提供了访问 Query 和 PostForm 参数的方法。
 // 提供了快速构造 String Data JSON HTML 响应的方法。
+// Context 由 engine 复用，handler 返回后不能再使用，包括 Params 和 Writer；
+// 需要在其他协程中使用时通过 Copy 得到一份拷贝。
 type Context struct {
 	// origin objects
-	Writer http.ResponseWriter
+	Writer ResponseWriter
 	Req    *http.Request
 
 	// request info
 	Path   string
 	Method string
-	Params map[string]string
+	Params map[string]string // Params 路由参数，随 Context 复用，handler 返回后会被清空
 
 	// response info
-	StatusCode int
+	StatusCode int // StatusCode 通过 SetStatusCode 设置的状态码，实际返回的状态码见 Writer.Status()
 
 	// middleware
 	handlers []HandlerFunc
@@ -35,6 +38,9 @@ type Context struct {
 
 	// engine pointer
 	engine *Engine
+
+	writer      responseWriter // writer Writer 的实现，随 Context 一起复用
+	paramValues []string       // paramValues 匹配路由时依次得到的参数值，复用底层数组
 }
 
 // Next 控制顺序执行 HandlerFunc
@@ -91,53 +97,80 @@ func (c *Context) String(code int, format string, values ...any) {
 	c.SetHeader("Content-Type", "text/plain")
 	c.SetStatusCode(code)
 
-	if _, err := c.Writer.Write([]byte(fmt.Sprintf(format, values...))); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
-	}
+	// 写入失败时 header 已经发送，连接通常也已经断开，无法再返回错误
+	_, _ = c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
 }
 
 // JSON 返回 json
+// 先编码到缓冲区，编码失败时 header 还没有发送，可以返回 500
 func (c *Context) JSON(code int, obj any) {
-	c.SetHeader("Content-Type", "application/json")
-	c.SetStatusCode(code)
-	encoder := json.NewEncoder(c.Writer)
-
-	if err := encoder.Encode(obj); err != nil {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(obj); err != nil {
 		http.Error(c.Writer, err.Error(), 500)
+		return
 	}
+
+	c.SetHeader("Content-Type", "application/json")
+	c.SetStatusCode(code)
+	_, _ = c.Writer.Write(buf.Bytes())
 }
 
 // Data 将数据作为 HTTP 回复的一部分写入连接。
 func (c *Context) Data(code int, data []byte) {
 	c.SetStatusCode(code)
-
-	if _, err := c.Writer.Write(data); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
-	}
+	_, _ = c.Writer.Write(data)
 }
 
 // HTML 返回 html
+// 和 JSON 一样先渲染到缓冲区，渲染失败时返回 500
 func (c *Context) HTML(code int, name string, data any) {
-	c.SetHeader("Content-Type", "text/html")
-	c.SetStatusCode(code)
-
 	//if _, err := c.Writer.Write([]byte(html)); err != nil {
 	//	http.Error(c.Writer, err.Error(), 500)
 	//}
 
 	// ExecuteTemplate 将与具有给定名称的 t 关联的模板应用于指定的数据对象，并将输出写入 wr。
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+	var buf bytes.Buffer
+	if err := c.engine.htmlTemplates.ExecuteTemplate(&buf, name, data); err != nil {
 		c.Fail(500, err.Error())
+		return
 	}
+
+	c.SetHeader("Content-Type", "text/html")
+	c.SetStatusCode(code)
+	_, _ = c.Writer.Write(buf.Bytes())
 }
 
-// newContext 初始化 Context
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		index:  -1,
+// reset 重置 Context 用于新的请求，保留 Params 和 paramValues 的内存
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writer.reset(w)
+	c.Writer = &c.writer
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	for k := range c.Params {
+		delete(c.Params, k)
+	}
+	c.StatusCode = 0
+	c.handlers = nil
+	c.index = -1
+	c.paramValues = c.paramValues[:0]
+}
+
+// Copy 返回 Context 的拷贝，可以在 handler 返回后使用，例如传给其他协程。
+// 拷贝只用于读取请求信息，不能写响应，也不能调用 Next。
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		StatusCode: c.StatusCode,
+		engine:     c.engine,
+	}
+	if c.Params != nil {
+		cp.Params = make(map[string]string, len(c.Params))
+		for k, v := range c.Params {
+			cp.Params[k] = v
+		}
 	}
+	return cp
 }
@@ -4,7 +4,8 @@ import (
 	"html/template"
 	"net/http"
 	"path"
-	"strings"
+	"strconv"
+	"sync"
 )
 
 // HandlerFunc 路由匹配成功后执行的方法
@@ -19,6 +20,7 @@ type (
 		engine      *Engine
 		middlewares []HandlerFunc // support middleware
 		parent      *RouterGroup
+		hasRoutes   bool // hasRoutes group 或子分组已经注册了路由，之后不能再 Use
 	}
 
 	// Engine 引擎
@@ -29,6 +31,7 @@ type (
 		groups        []*RouterGroup
 		htmlTemplates *template.Template
 		funcMap       template.FuncMap
+		pool          sync.Pool // pool 复用 Context
 	}
 )
 
@@ -38,6 +41,9 @@ func New() *Engine {
 	engine := &Engine{router: newRouter()}
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.pool.New = func() any {
+		return &Context{engine: engine}
+	}
 	return engine
 }
 
@@ -60,27 +66,98 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 	return newGroup
 }
 
-// Use 添加中间件到群结构体
+// Use 添加中间件到群结构体。
+// 中间件在注册路由时合并到路由的处理链中，所以必须在 group 及其子分组注册路由之前调用，否则 panic；
+// engine 的中间件另外会在没有匹配到路由（404、405 和自动的 OPTIONS）时执行。
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
+	if group.hasRoutes {
+		panic("gee: Use must be called before registering routes on group " + strconv.Quote(group.prefix))
+	}
 	group.middlewares = append(group.middlewares, middlewares...)
 }
 
-// addRoute 添加路由
-func (group *RouterGroup) addRoute(method string, pattern string, handler HandlerFunc) {
+// addRoute 添加路由，路由的处理链依次为 parent 的中间件、group 的中间件和 handlers
+func (group *RouterGroup) addRoute(method string, pattern string, handlers []HandlerFunc) {
 	//key := method + "-" + pattern
 	//engine.router[key] = handler
+	if len(handlers) == 0 {
+		panic("gee: route " + method + " " + group.prefix + pattern + " must have at least one handler")
+	}
+	for g := group; g != nil; g = g.parent {
+		g.hasRoutes = true
+	}
 	pattern = group.prefix + pattern
-	group.engine.router.addRoute(method, pattern, handler)
+	group.engine.router.addRoute(method, pattern, combineHandlers(group.allMiddlewares(), handlers))
 }
 
-// GET 添加一个 get 请求路由
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+// allMiddlewares 返回 group 及所有 parent 的中间件，parent 的在前
+func (group *RouterGroup) allMiddlewares() []HandlerFunc {
+	if group.parent == nil {
+		return group.middlewares
+	}
+	return combineHandlers(group.parent.allMiddlewares(), group.middlewares)
+}
+
+// combineHandlers 拼接两组 HandlerFunc，返回新的切片，不会修改 a 的底层数组
+func combineHandlers(a, b []HandlerFunc) []HandlerFunc {
+	handlers := make([]HandlerFunc, 0, len(a)+len(b))
+	handlers = append(handlers, a...)
+	return append(handlers, b...)
+}
+
+// anyMethods Any 注册的请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// Handle 添加任意请求方法的路由
+func (group *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) {
+	group.addRoute(method, pattern, handlers)
+}
+
+// Any 为所有的请求方法添加同一个路由
+func (group *RouterGroup) Any(pattern string, handlers ...HandlerFunc) {
+	for _, method := range anyMethods {
+		group.addRoute(method, pattern, handlers)
+	}
+}
+
+// GET 添加一个 get 请求路由，没有注册 HEAD 时 HEAD 请求也会使用该路由。
+// handlers 依次执行，可以为单个路由添加中间件，例如：r.GET("/admin", auth, handler)
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("GET", pattern, handlers)
 }
 
 // POST 添加一个 post 请求
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("POST", pattern, handlers)
+}
+
+// PUT 添加一个 put 请求路由
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("PUT", pattern, handlers)
+}
+
+// DELETE 添加一个 delete 请求路由
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("DELETE", pattern, handlers)
+}
+
+// PATCH 添加一个 patch 请求路由
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("PATCH", pattern, handlers)
+}
+
+// HEAD 添加一个 head 请求路由
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("HEAD", pattern, handlers)
+}
+
+// OPTIONS 添加一个 options 请求路由，没有注册时会自动返回路径支持的方法
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("OPTIONS", pattern, handlers)
 }
 
 func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
@@ -127,15 +204,11 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	//} else {
 	//	fmt.Fprintf(w, "404 NOT FOUND: %s\n", req.URL)
 	//}
-	var middlewares []HandlerFunc
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
-	}
-
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = engine
+	// 分组的中间件在注册路由时已经合并，这里只放入 engine 的中间件，用于没有匹配到路由的情况
+	// handler panic 时 Context 同样放回，handler 返回后不能再持有 Context，见 Context.Copy
+	c := engine.pool.Get().(*Context)
+	defer engine.pool.Put(c)
+	c.reset(w, req)
+	c.handlers = engine.middlewares
 	engine.router.handle(c)
 }
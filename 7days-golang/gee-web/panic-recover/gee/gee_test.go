@@ -4,7 +4,17 @@
 
 package gee
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestNestedGroup(t *testing.T) {
 	r := New()
@@ -18,3 +28,188 @@ func TestNestedGroup(t *testing.T) {
 		t.Fatal("v2 prefix should be /v1/v2")
 	}
 }
+
+func TestMethods(t *testing.T) {
+	r := New()
+	ok := func(c *Context) { c.String(http.StatusOK, "%s %s", c.Method, c.Path) }
+	r.GET("/hello/:name", ok)
+	r.PUT("/hello/:name", ok)
+	r.DELETE("/hello/:name", ok)
+	r.PATCH("/hello/:name", ok)
+	r.Handle("PROPFIND", "/dav", ok)
+	r.Any("/any", ok)
+	r.OPTIONS("/custom", func(c *Context) { c.String(http.StatusOK, "custom") })
+	r.POST("/custom", ok)
+
+	tests := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{"GET", "/hello/gee", http.StatusOK, ""},
+		{"PUT", "/hello/gee", http.StatusOK, ""},
+		{"DELETE", "/hello/gee", http.StatusOK, ""},
+		{"PATCH", "/hello/gee", http.StatusOK, ""},
+		{"PROPFIND", "/dav", http.StatusOK, ""},
+		{"POST", "/any", http.StatusOK, ""},
+		{"TRACE", "/any", http.StatusOK, ""},
+		{"HEAD", "/hello/gee", http.StatusOK, ""},
+		{"POST", "/hello/gee", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PATCH, PUT"},
+		{"OPTIONS", "/hello/gee", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS, PATCH, PUT"},
+		{"OPTIONS", "/custom", http.StatusOK, ""},
+		{"GET", "/custom", http.StatusMethodNotAllowed, "OPTIONS, POST"},
+		{"GET", "/unknown", http.StatusNotFound, ""},
+		{"OPTIONS", "/unknown", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Allow") != tt.allow {
+			t.Fatalf("%s %s: expect %d with Allow %q, got %d with Allow %q", tt.method, tt.path, tt.code, tt.allow, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	ok := func(c *Context) { c.String(http.StatusOK, "ok") }
+
+	r := New()
+	r.Use(mark("engine"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	admin := v1.Group("/admin")
+	admin.Use(mark("admin"))
+
+	r.GET("/v10/hello", ok)
+	v1.GET("/hello", ok)
+	admin.GET("/users", mark("route1"), mark("route2"), ok)
+
+	tests := []struct {
+		method, path string
+		trace        string
+	}{
+		{"GET", "/v10/hello", "engine"},
+		{"GET", "/v1/hello", "engine v1"},
+		{"GET", "/v1/admin/users", "engine v1 admin route1 route2"},
+		{"GET", "/v1/unknown", "engine"},
+		{"POST", "/v1/hello", "engine"},
+	}
+
+	for _, tt := range tests {
+		trace = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if got := strings.Join(trace, " "); got != tt.trace {
+			t.Fatalf("%s %s: expect %q, but got %q", tt.method, tt.path, tt.trace, got)
+		}
+	}
+
+	// 注册路由后再 Use 不会生效，直接 panic，父分组同样不能再 Use
+	for name, group := range map[string]*RouterGroup{"engine": r.RouterGroup, "v1": v1, "admin": admin} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Use on %s after routes should panic", name)
+				}
+			}()
+			group.Use(mark("late"))
+		}()
+	}
+	r.Group("/v2").Use(mark("v2"))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("route without handlers should panic")
+		}
+	}()
+	r.GET("/empty")
+}
+
+func TestResponseWriter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gee.css"), []byte("body {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	r := New()
+	r.Use(Logger())
+	r.Static("/assets", dir)
+	r.GET("/json", func(c *Context) {
+		c.JSON(http.StatusOK, H{"bad": make(chan int)})
+	})
+	r.GET("/twice", func(c *Context) {
+		c.String(http.StatusCreated, "created")
+		c.SetStatusCode(http.StatusAccepted)
+		if c.Writer.Status() != http.StatusCreated || c.Writer.Size() != len("created") || !c.Writer.Written() {
+			t.Errorf("expect 201 %d written, but got %d %d %v", len("created"), c.Writer.Status(), c.Writer.Size(), c.Writer.Written())
+		}
+	})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/assets/gee.css", http.StatusOK},
+		{"/assets/", http.StatusNotFound},
+		{"/json", http.StatusInternalServerError},
+		{"/twice", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Fatalf("%s: expect %d, but got %d", tt.path, tt.code, w.Code)
+		}
+		// Logger 记录实际返回的状态码，包括 http.FileServer 写入的
+		if want := fmt.Sprintf("[%d]", tt.code); !strings.Contains(buf.String(), want) {
+			t.Fatalf("%s: expect log %s, but got %s", tt.path, want, buf.String())
+		}
+	}
+}
+
+func TestContextReuse(t *testing.T) {
+	r := New()
+	r.GET("/hello/:name", func(c *Context) {
+		c.String(http.StatusOK, "%s %v", c.Param("name"), c.Params)
+	})
+	r.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "%d %v", len(c.Params), c.StatusCode)
+	})
+	var copies []*Context
+	r.GET("/copy/:name", func(c *Context) {
+		copies = append(copies, c.Copy())
+	})
+
+	for i, tt := range []struct{ path, body string }{
+		{"/hello/gee", "gee map[name:gee]"},
+		{"/hello", "0 0"},
+		{"/hello/geektutu", "geektutu map[name:geektutu]"},
+		{"/hello", "0 0"},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Body.String() != tt.body {
+			t.Fatalf("request %d %s: expect %q, but got %q", i, tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	// Copy 得到的 Context 不受之后请求的影响
+	for _, name := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/copy/"+name, nil))
+	}
+	if copies[0].Param("name") != "a" || copies[1].Param("name") != "b" || copies[0].Path != "/copy/a" {
+		t.Fatalf("expect copies to keep their params, got %v %v", copies[0].Params, copies[1].Params)
+	}
+}
@@ -16,6 +16,6 @@ func Logger() HandlerFunc {
 		// %d 十进制数字
 		// %s 字符串 或 []byte
 		// %v 值的默认格式表示
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		log.Printf("[%d] %s in %v", c.Writer.Status(), c.Req.RequestURI, time.Since(t))
 	}
 }
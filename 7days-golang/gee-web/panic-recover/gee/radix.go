@@ -0,0 +1,200 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// radix 压缩前缀树，作为路由匹配树
+
+package gee
+
+import (
+	"fmt"
+	"strings"
+)
+
+// radixNode 压缩前缀树的节点。
+// 静态节点的 path 是路由中的一段字符串，相同前缀的路由共用节点，例如：/hello/ 下的 bar 和 baz 共用 ba；
+// 参数节点和通配节点的 path 是参数名，分别匹配一段路径和剩余的全部路径。
+// 匹配的优先级为 静态 > :param > *catchall，静态节点匹配失败时回退到参数节点。
+type radixNode struct {
+	path       string       // path 静态节点为匹配的字符串，参数节点和通配节点为参数名
+	children   []*radixNode // children 静态子节点，首字符互不相同
+	param      *radixNode   // param :param 子节点，同一位置只能有一个
+	catchAll   *radixNode   // catchAll *catchall 子节点，同一位置只能有一个
+	pattern    string       // pattern 在该节点结束的路由，例如：/p/:lang，为空表示没有路由
+	paramNames []string     // paramNames 路由中参数的名称，按出现的顺序，注册时计算
+}
+
+// token 路由拆分后的一段，静态字符串或者参数
+type token struct {
+	static string // static 静态字符串，参数时为空
+	wild   byte   // wild ':' 或 '*'，静态字符串时为 0
+	name   string // name 参数名
+}
+
+// tokenize 将路由拆分为静态字符串和参数，例如：/hello/:name/x 拆分为 /hello/、:name、/x
+func tokenize(pattern string) []token {
+	var tokens []token
+	static := ""
+	for _, part := range parsePattern(pattern) {
+		if part[0] != ':' && part[0] != '*' {
+			static += "/" + part
+			continue
+		}
+		tokens = append(tokens, token{static: static + "/"}, token{wild: part[0], name: part[1:]})
+		static = ""
+	}
+	if static != "" || len(tokens) == 0 {
+		if static == "" {
+			static = "/"
+		}
+		tokens = append(tokens, token{static: static})
+	}
+	return tokens
+}
+
+// insert 插入路由，路由重复或者同一位置的参数名不同时 panic
+func (n *radixNode) insert(pattern string) {
+	var names []string
+	for _, t := range tokenize(pattern) {
+		switch t.wild {
+		case 0:
+			n = n.insertStatic(t.static)
+		case ':':
+			if t.name == "" {
+				panic(fmt.Sprintf("gee: wildcard in route %s must have a name", pattern))
+			}
+			if n.param == nil {
+				n.param = &radixNode{path: t.name}
+			} else if n.param.path != t.name {
+				panic(fmt.Sprintf("gee: :%s in route %s conflicts with existing :%s", t.name, pattern, n.param.path))
+			}
+			n = n.param
+			names = append(names, t.name)
+		case '*':
+			if n.catchAll == nil {
+				n.catchAll = &radixNode{path: t.name}
+			} else if n.catchAll.path != t.name {
+				panic(fmt.Sprintf("gee: *%s in route %s conflicts with existing *%s", t.name, pattern, n.catchAll.path))
+			}
+			n = n.catchAll
+			names = append(names, t.name)
+		}
+	}
+
+	if n.pattern != "" {
+		panic(fmt.Sprintf("gee: route %s conflicts with existing route %s", pattern, n.pattern))
+	}
+	n.pattern = pattern
+	n.paramNames = names
+}
+
+// insertStatic 插入静态字符串，返回字符串结束处的节点。
+// 和已有的子节点有公共前缀时，在前缀处拆分子节点。
+func (n *radixNode) insertStatic(s string) *radixNode {
+	for _, child := range n.children {
+		l := commonPrefix(child.path, s)
+		if l == 0 {
+			continue
+		}
+
+		if l < len(child.path) {
+			// child 拆分为前缀和剩余部分，剩余部分继承 child 原有的子节点和路由
+			rest := *child
+			rest.path = child.path[l:]
+			*child = radixNode{path: child.path[:l], children: []*radixNode{&rest}}
+		}
+		if l == len(s) {
+			return child
+		}
+		return child.insertStatic(s[l:])
+	}
+
+	child := &radixNode{path: s}
+	n.children = append(n.children, child)
+	return child
+}
+
+// commonPrefix 返回公共前缀的长度
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// search 匹配 n 之后剩余的 path，返回路由结束的节点和依次匹配到的参数值
+func (n *radixNode) search(path string, values []string) (*radixNode, []string) {
+	if path == "" {
+		if n.pattern == "" {
+			return nil, nil
+		}
+		return n, values
+	}
+
+	for _, child := range n.children {
+		if child.path[0] == path[0] {
+			if strings.HasPrefix(path, child.path) {
+				if result, vs := child.search(path[len(child.path):], values); result != nil {
+					return result, vs
+				}
+			}
+			break
+		}
+	}
+
+	if n.param != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			if result, vs := n.param.search(path[end:], append(values, path[:end])); result != nil {
+				return result, vs
+			}
+		}
+	}
+
+	if n.catchAll != nil && n.catchAll.pattern != "" {
+		return n.catchAll, append(values, path)
+	}
+	return nil, nil
+}
+
+func (n *radixNode) travel(list *[]*radixNode) {
+	if n.pattern != "" {
+		*list = append(*list, n)
+	}
+	for _, child := range n.children {
+		child.travel(list)
+	}
+	if n.param != nil {
+		n.param.travel(list)
+	}
+	if n.catchAll != nil {
+		n.catchAll.travel(list)
+	}
+}
+
+// cleanPath 去掉请求路径中重复的和结尾的 /，和 parsePattern 拆分路由的规则一致，
+// 已经是规范的路径时不分配内存
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] == '/' && !strings.Contains(p, "//") && (len(p) == 1 || p[len(p)-1] != '/') {
+		return p
+	}
+
+	var b strings.Builder
+	for _, part := range strings.Split(p, "/") {
+		if part != "" {
+			b.WriteByte('/')
+			b.WriteString(part)
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
@@ -7,12 +7,13 @@ package gee
 import (
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	roots    map[string]*radixNode
+	handlers map[string][]HandlerFunc // handlers 路由完整的处理链，包括分组的中间件
 }
 
 // getHandlesKey 获取 router.handlers 的 key
@@ -20,83 +21,131 @@ func (r router) getHandlesKey(method string, pattern string) string {
 	return method + "-" + pattern
 }
 
-// addRoute 添加路由
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+// addRoute 添加路由，和已有的路由冲突时 panic
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) {
 	log.Printf("Route %4s - %s", method, pattern)
-	parts := parsePattern(pattern) // 可以把这个逻辑放入 node.insert 中
 
 	if _, ok := r.roots[method]; !ok {
-		r.roots[method] = &node{}
+		r.roots[method] = &radixNode{}
 	}
 
-	r.roots[method].insert(pattern, parts, 0)
+	r.roots[method].insert(pattern)
 	key := r.getHandlesKey(method, pattern)
-	r.handlers[key] = handler
+	r.handlers[key] = handlers
 }
 
-// getRoute 获取路由信息
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+// search 匹配路由，返回路由结束的节点和追加到 values 之后的参数值
+func (r *router) search(method string, path string, values []string) (*radixNode, []string) {
 	root, ok := r.roots[method]
-
 	if !ok {
 		return nil, nil
 	}
+	return root.search(cleanPath(path), values)
+}
 
-	n := root.search(searchParts, 0)
-
-	if n != nil {
-		parts := parsePattern(n.pattern)
-
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
+// getRoute 获取路由信息，参数的名称在注册路由时已经计算好，不需要再拆解路由
+func (r *router) getRoute(method string, path string) (*radixNode, map[string]string) {
+	n, values := r.search(method, path, nil)
+	if n == nil {
+		return nil, nil
+	}
 
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-			}
+	params := make(map[string]string, len(n.paramNames))
+	for i, name := range n.paramNames {
+		if name != "" {
+			params[name] = values[i]
 		}
-
-		return n, params
 	}
-
-	return nil, nil
+	return n, params
 }
 
-func (r *router) getRoutes(method string) []*node {
+func (r *router) getRoutes(method string) []*radixNode {
 	root, ok := r.roots[method]
 	if !ok {
 		return nil
 	}
-	nodes := make([]*node, 0)
+	nodes := make([]*radixNode, 0)
 	root.travel(&nodes)
 	return nodes
 }
 
-// handle 根据请求的 Context 获取请求的处理方法并执行
+// handle 根据请求的 Context 获取请求的处理链并执行。
+// 找不到对应方法的路由时，在 c.handlers 中已有的中间件之后：
+//   - HEAD 请求使用 GET 的路由，net/http 会丢弃响应的 body
+//   - OPTIONS 请求返回 204，Allow 头中是路径支持的方法
+//   - 路径在其他方法下存在时返回 405，同样带有 Allow 头
+//   - 否则返回 404
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
+	method := c.Method
+	n, values := r.search(method, c.Path, c.paramValues[:0])
+
+	if n == nil && method == http.MethodHead {
+		method = http.MethodGet
+		n, values = r.search(method, c.Path, c.paramValues[:0])
+	}
 
 	if n != nil {
-		c.Params = params
-		key := r.getHandlesKey(c.Method, n.pattern)
-		c.handlers = append(c.handlers, r.handlers[key])
+		// 复用 Context 中的 Params 和 paramValues
+		c.paramValues = values
+		if len(n.paramNames) > 0 && c.Params == nil {
+			c.Params = make(map[string]string, len(n.paramNames))
+		}
+		for i, name := range n.paramNames {
+			if name != "" {
+				c.Params[name] = values[i]
+			}
+		}
+		key := r.getHandlesKey(method, n.pattern)
+		c.handlers = r.handlers[key]
+	} else if allow := r.allowedMethods(c.Path); len(allow) > 0 {
+		c.handlers = combineHandlers(c.handlers, []HandlerFunc{func(c *Context) {
+			c.SetHeader("Allow", strings.Join(allow, ", "))
+			if c.Method == http.MethodOptions {
+				c.SetStatusCode(http.StatusNoContent)
+				return
+			}
+			c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n", c.Path)
+		}})
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
+		c.handlers = combineHandlers(c.handlers, []HandlerFunc{func(c *Context) {
 			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		}})
 	}
 
 	c.Next()
 }
 
+// allowedMethods 返回 path 可以匹配的请求方法，按字母排序。
+// 有 GET 时包括 HEAD，有任意方法时包括 OPTIONS；path 不存在时返回 nil。
+func (r *router) allowedMethods(path string) []string {
+	set := make(map[string]bool)
+	for method := range r.roots {
+		if n, _ := r.getRoute(method, path); n != nil {
+			set[method] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	if set[http.MethodGet] {
+		set[http.MethodHead] = true
+	}
+	set[http.MethodOptions] = true
+
+	allow := make([]string, 0, len(set))
+	for method := range set {
+		allow = append(allow, method)
+	}
+	sort.Strings(allow)
+	return allow
+}
+
 // newRouter 初始化 router
 func newRouter() *router {
 	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots:    make(map[string]*radixNode),
+		handlers: make(map[string][]HandlerFunc),
 	}
 }
 
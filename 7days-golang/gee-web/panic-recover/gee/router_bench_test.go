@@ -0,0 +1,180 @@
+// Copyright 2022 Cathay.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gee
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchRoutes 和 benchPaths 模拟一组常见的 API 路由和请求
+var (
+	benchRoutes = []string{
+		"/",
+		"/login",
+		"/users",
+		"/users/:id",
+		"/users/:id/repos",
+		"/users/:id/repos/:repo",
+		"/users/:id/repos/:repo/issues",
+		"/users/:id/repos/:repo/issues/:number",
+		"/users/:id/followers",
+		"/users/:id/following",
+		"/orgs/:org",
+		"/orgs/:org/members",
+		"/orgs/:org/members/:user",
+		"/search/repositories",
+		"/search/code",
+		"/search/issues",
+		"/assets/*filepath",
+	}
+	benchPaths = []string{
+		"/",
+		"/login",
+		"/users/geektutu",
+		"/users/geektutu/repos/7days-golang/issues/42",
+		"/users/geektutu/following",
+		"/orgs/golang/members/rsc",
+		"/search/issues",
+		"/assets/css/gee.css",
+	}
+)
+
+// trieNode 之前的路由匹配树（单词查找树）的节点，已经由 radix.go 代替，保留在这里用于 benchmark 对比
+type trieNode struct {
+	pattern  string      // pattern 待匹配路由，例如：/p/:lang，用来匹配路由对应的 HandlerFunc
+	part     string      // part 路由中的一部分，例如：:lang
+	children []*trieNode // children 子节点，例如：[p, lang]
+	isWild   bool        // isWild 是否模糊匹配，part 含有 `:` 或 `*` 时为 true
+}
+
+// insert 插入路由树节点，根据`/`拆分路由，从前往后，依次检查一样的放在一个 node 里，
+// 后面节点依次放入 children 里，不理解可以查看[图片](https://geektutu.com/post/gee-day3/trie_router.jpg)
+func (n *trieNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+
+	part := parts[height]
+	child := n.matchChild(part)
+
+	if child == nil {
+		child = &trieNode{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		n.children = append(n.children, child)
+	}
+
+	child.insert(pattern, parts, height+1)
+}
+
+// search 从树节点中找到匹配的最终节点信息
+func (n *trieNode) search(parts []string, height int) *trieNode {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+
+		return n
+	}
+
+	part := parts[height]
+	children := n.matchChildren(part)
+
+	for _, child := range children {
+		result := child.search(parts, height+1)
+
+		if result != nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// matchChild 匹配子节点
+func (n *trieNode) matchChild(part string) *trieNode {
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// matchChildren 匹配 子节点们
+// children 是 child 的复数
+func (n *trieNode) matchChildren(part string) []*trieNode {
+	nodes := make([]*trieNode, 0)
+
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			nodes = append(nodes, child)
+		}
+	}
+
+	return nodes
+}
+
+// trieGetRoute 使用 trieNode 匹配，和之前 router.getRoute 的实现一致
+func trieGetRoute(root *trieNode, path string) (*trieNode, map[string]string) {
+	searchParts := parsePattern(path)
+	params := make(map[string]string)
+
+	n := root.search(searchParts, 0)
+	if n == nil {
+		return nil, nil
+	}
+
+	for index, part := range parsePattern(n.pattern) {
+		if part[0] == ':' {
+			params[part[1:]] = searchParts[index]
+		}
+		if part[0] == '*' && len(part) > 1 {
+			params[part[1:]] = strings.Join(searchParts[index:], "/")
+		}
+	}
+	return n, params
+}
+
+func BenchmarkTrie(b *testing.B) {
+	root := &trieNode{}
+	for _, pattern := range benchRoutes {
+		root.insert(pattern, parsePattern(pattern), 0)
+	}
+	for _, path := range benchPaths {
+		if n, _ := trieGetRoute(root, path); n == nil {
+			b.Fatalf("%s should match", path)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			trieGetRoute(root, path)
+		}
+	}
+}
+
+func BenchmarkRadix(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	for _, path := range benchPaths {
+		if n, _ := r.getRoute("GET", path); n == nil {
+			b.Fatalf("%s should match", path)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			r.getRoute("GET", path)
+		}
+	}
+}
@@ -77,3 +77,72 @@ func TestGetRoutes(t *testing.T) {
 		t.Fatal("the number of routes shoule be 4")
 	}
 }
+
+func TestRoutePriority(t *testing.T) {
+	// 注册顺序不影响优先级：静态 > :param > *catchall
+	patterns := []string{"/src/*filepath", "/src/:name", "/src/main.go", "/src/bar", "/src/baz/:id"}
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 0, 4, 1, 3}} {
+		r := newRouter()
+		for _, i := range order {
+			r.addRoute("GET", patterns[i], nil)
+		}
+
+		cases := []struct {
+			path    string
+			pattern string
+			params  map[string]string
+		}{
+			{"/src/main.go", "/src/main.go", map[string]string{}},
+			{"/src/bar", "/src/bar", map[string]string{}},
+			{"/src/ba", "/src/:name", map[string]string{"name": "ba"}},
+			{"/src/bax", "/src/:name", map[string]string{"name": "bax"}},
+			{"/src/baz", "/src/:name", map[string]string{"name": "baz"}},
+			{"/src/baz/1", "/src/baz/:id", map[string]string{"id": "1"}},
+			{"/src/bar/1", "/src/*filepath", map[string]string{"filepath": "bar/1"}},
+			{"/src/a/b.go", "/src/*filepath", map[string]string{"filepath": "a/b.go"}},
+			{"//src//main.go/", "/src/main.go", map[string]string{}},
+		}
+		for _, c := range cases {
+			n, ps := r.getRoute("GET", c.path)
+			if n == nil || n.pattern != c.pattern || !reflect.DeepEqual(ps, c.params) {
+				t.Fatalf("order %v, path %s: expect %s %v, but got %v %v", order, c.path, c.pattern, c.params, n, ps)
+			}
+		}
+
+		if n, _ := r.getRoute("GET", "/src"); n != nil {
+			t.Fatalf("order %v: /src shouldn't match, but got %s", order, n.pattern)
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	cases := []struct {
+		existing string
+		pattern  string
+	}{
+		{"/hello/:name", "/hello/:id"},
+		{"/hello/:name/a", "/hello/:id/b"},
+		{"/assets/*filepath", "/assets/*path"},
+		{"/assets/*filepath", "/assets/*"},
+		{"/hello/:name", "/hello/:name"},
+		{"/hello", "/hello/"},
+		{"/", "/:"},
+	}
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s after %s should panic", c.pattern, c.existing)
+				}
+			}()
+			r := newRouter()
+			r.addRoute("GET", c.existing, nil)
+			r.addRoute("GET", c.pattern, nil)
+		}()
+	}
+
+	// 不同的请求方法互不影响
+	r := newRouter()
+	r.addRoute("GET", "/hello/:name", nil)
+	r.addRoute("POST", "/hello/:id", nil)
+}